@@ -3,6 +3,8 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"math/big"
+	"path/filepath"
 	"strings"
 
 	"github.com/tatsuya4559/monkey/token"
@@ -10,6 +12,8 @@ import (
 
 type Node interface {
 	TokenLiteral() string
+	Pos() token.Position // position of the first character of the node
+	End() token.Position // position immediately after the node
 	fmt.Stringer
 }
 
@@ -25,6 +29,10 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+
+	// Resolved is set once the resolver has set the bindings of the
+	// program without errors.
+	Resolved bool
 }
 
 func (p *Program) TokenLiteral() string {
@@ -34,6 +42,20 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if n := len(p.Statements); n > 0 {
+		return p.Statements[n-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -45,19 +67,30 @@ func (p *Program) String() string {
 }
 
 type LetStatement struct {
-	Token token.Token // let token
-	Name  *Identifier
-	Value Expression
+	Token    token.Token // let token
+	Name     *Identifier
+	Value    Expression
+	Exported bool // preceded by export
 }
 
 func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.Name.End()
+}
 
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
+	if ls.Exported {
+		out.WriteString("export ")
+	}
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
 	out.WriteString(" = ")
@@ -74,12 +107,25 @@ func (ls *LetStatement) String() string {
 type Identifier struct {
 	Token token.Token
 	Value string
+
+	// Binding is set by the resolver for the local variables. Global
+	// variables and builtins have none and are looked up by name.
+	Binding *Binding
+}
+
+// Binding tells where the variable of an identifier is stored: in Slot of
+// the environment Depth scopes out from the identifier.
+type Binding struct {
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+func (i *Identifier) End() token.Position { return i.Token.End }
 
 func (i *Identifier) String() string {
 	return i.Value
@@ -94,6 +140,13 @@ func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+}
 
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -118,6 +171,18 @@ func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
+func (es *ExpressionStatement) Pos() token.Position {
+	if es.Expression != nil {
+		return es.Expression.Pos()
+	}
+	return es.Token.Pos
+}
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
 
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -129,16 +194,34 @@ func (es *ExpressionStatement) String() string {
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // the value instead if it does not fit in int64
 }
 
 func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position { return il.Token.End }
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+func (fl *FloatLiteral) Pos() token.Position { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position { return fl.Token.End }
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -148,10 +231,42 @@ func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position { return sl.Token.End }
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+// InterpolatedString is a string literal with embedded expressions.
+// Parts alternates between *StringLiteral and embedded expressions,
+// starting and ending with a *StringLiteral.
+type InterpolatedString struct {
+	Token token.Token // the STRING_HEAD token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) TokenLiteral() string {
+	return is.Token.Literal
+}
+func (is *InterpolatedString) Pos() token.Position { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Position {
+	return is.Parts[len(is.Parts)-1].End()
+}
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for i, part := range is.Parts {
+		if i%2 == 0 {
+			out.WriteString(part.String())
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token // prefix operator
 	Operator string
@@ -162,6 +277,8 @@ func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position { return pe.Right.End() }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -184,6 +301,8 @@ func (ie *InfixExpression) expressionNode() {}
 func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *InfixExpression) Pos() token.Position { return ie.Left.Pos() }
+func (ie *InfixExpression) End() token.Position { return ie.Right.End() }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -205,6 +324,8 @@ func (b *Boolean) expressionNode() {}
 func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
+func (b *Boolean) End() token.Position { return b.Token.End }
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -220,6 +341,13 @@ func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return ie.Consequence.End()
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -237,14 +365,17 @@ func (ie *IfExpression) String() string {
 }
 
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // { token
 	Statements []Statement
+	RBrace     token.Token
 }
 
 func (bs *BlockStatement) statementNode() {}
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position { return bs.RBrace.End }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -257,7 +388,10 @@ func (bs *BlockStatement) String() string {
 
 type FunctionLiteral struct {
 	Token      token.Token
+	Name       string // set when the literal is bound by let
 	Parameters []*Identifier
+	Defaults   []Expression // default values by parameter; nil if there are none
+	Rest       *Identifier  // parameter collecting the remaining arguments
 	Body       *BlockStatement
 }
 
@@ -265,13 +399,12 @@ func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position { return fl.Body.End() }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
+	params := FormatParameters(fl.Parameters, fl.Defaults, fl.Rest)
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
@@ -282,16 +415,40 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// FormatParameters returns the source form of function parameters.
+func FormatParameters(
+	params []*Identifier,
+	defaults []Expression,
+	rest *Identifier,
+) []string {
+	formatted := []string{}
+	for i, p := range params {
+		if defaults != nil && defaults[i] != nil {
+			formatted = append(formatted, p.String()+" = "+defaults[i].String())
+		} else {
+			formatted = append(formatted, p.String())
+		}
+	}
+	if rest != nil {
+		formatted = append(formatted, "..."+rest.String())
+	}
+	return formatted
+}
+
 type CallExpression struct {
-	Token     token.Token
+	Token     token.Token // ( token
 	Function  Expression
 	Arguments []Expression
+	RParen    token.Token
+	Tail      bool // in tail position in the body of a function literal
 }
 
 func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Pos() token.Position { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Position { return ce.RParen.End }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -309,14 +466,17 @@ func (ce *CallExpression) String() string {
 }
 
 type ArrayLiteral struct {
-	Token    token.Token
+	Token    token.Token // [ token
 	Elements []Expression
+	RBracket token.Token
 }
 
 func (al *ArrayLiteral) expressionNode() {}
 func (al *ArrayLiteral) TokenLiteral() string {
 	return al.Token.Literal
 }
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position { return al.RBracket.End }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -332,16 +492,36 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// MemberExpression is object.member.
+type MemberExpression struct {
+	Token  token.Token // . token
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+func (me *MemberExpression) Pos() token.Position { return me.Object.Pos() }
+func (me *MemberExpression) End() token.Position { return me.Member.End() }
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Member.String() + ")"
+}
+
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token    token.Token // [ token
+	Left     Expression
+	Index    Expression
+	RBracket token.Token
 }
 
 func (ie *IndexExpression) expressionNode() {}
 func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IndexExpression) Pos() token.Position { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position { return ie.RBracket.End }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -354,21 +534,63 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is left[start:stop:step]. Omitted parts are nil.
+type SliceExpression struct {
+	Token    token.Token // [ token
+	Left     Expression
+	Start    Expression
+	Stop     Expression
+	Step     Expression
+	RBracket token.Token
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+func (se *SliceExpression) Pos() token.Position { return se.Left.Pos() }
+func (se *SliceExpression) End() token.Position { return se.RBracket.End }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.Stop != nil {
+		out.WriteString(se.Stop.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 type HashLiteral struct {
-	Token token.Token
-	Pairs map[Expression]Expression
+	Token  token.Token // { token
+	Pairs  map[Expression]Expression
+	Keys   []Expression // keys of Pairs in source order
+	RBrace token.Token
 }
 
 func (hl *HashLiteral) expressionNode() {}
 func (hl *HashLiteral) TokenLiteral() string {
 	return hl.Token.Literal
 }
+func (hl *HashLiteral) Pos() token.Position { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position { return hl.RBrace.End }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -388,6 +610,8 @@ func (ml *MacroLiteral) expressionNode() {}
 func (ml *MacroLiteral) TokenLiteral() string {
 	return ml.Token.Literal
 }
+func (ml *MacroLiteral) Pos() token.Position { return ml.Token.Pos }
+func (ml *MacroLiteral) End() token.Position { return ml.Body.End() }
 func (ml *MacroLiteral) String() string {
 	var out bytes.Buffer
 
@@ -415,6 +639,8 @@ func (ws *WhileStatement) statementNode() {}
 func (ws *WhileStatement) TokenLiteral() string {
 	return ws.Token.Literal
 }
+func (ws *WhileStatement) Pos() token.Position { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position { return ws.Body.End() }
 func (ws *WhileStatement) String() string {
 	var out bytes.Buffer
 
@@ -426,3 +652,197 @@ func (ws *WhileStatement) String() string {
 
 	return out.String()
 }
+
+// ForStatement is a for-in loop. Key is nil in the single variable
+// form `for x in xs`.
+type ForStatement struct {
+	Token    token.Token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *ForStatement) Pos() token.Position { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position { return fs.Body.End() }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fs.TokenLiteral() + " ")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String() + ", ")
+	}
+	out.WriteString(fs.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString("{")
+	out.WriteString(fs.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type TryExpression struct {
+	Token   token.Token
+	Block   *BlockStatement
+	Param   *Identifier // nil if there is no catch clause
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (te *TryExpression) expressionNode() {}
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+func (te *TryExpression) Pos() token.Position { return te.Token.Pos }
+func (te *TryExpression) End() token.Position {
+	if te.Finally != nil {
+		return te.Finally.End()
+	}
+	if te.Catch != nil {
+		return te.Catch.End()
+	}
+	return te.Block.End()
+}
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch(" + te.Param.String() + ") ")
+		out.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
+
+type ThrowExpression struct {
+	Token token.Token
+	Value Expression
+}
+
+func (te *ThrowExpression) expressionNode() {}
+func (te *ThrowExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+func (te *ThrowExpression) Pos() token.Position { return te.Token.Pos }
+func (te *ThrowExpression) End() token.Position { return te.Value.End() }
+func (te *ThrowExpression) String() string {
+	return te.TokenLiteral() + " " + te.Value.String()
+}
+
+// SpreadExpression expands an array into the arguments of a call or
+// the elements of an array literal.
+type SpreadExpression struct {
+	Token token.Token // ... token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode() {}
+func (se *SpreadExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+func (se *SpreadExpression) Pos() token.Position { return se.Token.Pos }
+func (se *SpreadExpression) End() token.Position { return se.Value.End() }
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
+// AssignExpression assigns to a variable or an element of an array or hash.
+// Operator is "=" or a compound assignment such as "+=".
+type AssignExpression struct {
+	Token    token.Token // assignment operator
+	Target   Expression  // *Identifier or *IndexExpression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) Pos() token.Position { return ae.Target.Pos() }
+func (ae *AssignExpression) End() token.Position { return ae.Value.End() }
+func (ae *AssignExpression) String() string {
+	return ae.Target.String() + " " + ae.Operator + " " + ae.Value.String()
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BreakStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position { return bs.Token.End }
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+}
+
+// ImportStatement is import "path" or import "path" as alias.
+type ImportStatement struct {
+	Token token.Token // import token
+	Path  *StringLiteral
+	Alias *Identifier // nil if omitted
+}
+
+func (is *ImportStatement) statementNode() {}
+func (is *ImportStatement) TokenLiteral() string {
+	return is.Token.Literal
+}
+func (is *ImportStatement) Pos() token.Position { return is.Token.Pos }
+func (is *ImportStatement) End() token.Position {
+	if is.Alias != nil {
+		return is.Alias.End()
+	}
+	return is.Path.End()
+}
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fmt.Sprintf("import %q", is.Path.Value))
+	if is.Alias != nil {
+		out.WriteString(" as ")
+		out.WriteString(is.Alias.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// Name returns the name the import statement binds: the alias or the name
+// of the file without its extension.
+func (is *ImportStatement) Name() string {
+	if is.Alias != nil {
+		return is.Alias.Value
+	}
+	name := filepath.Base(is.Path.Value)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+func (cs *ContinueStatement) Pos() token.Position { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position { return cs.Token.End }
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+}
@@ -19,10 +19,28 @@ func Modify(node Node, modifier ModifierFunc) Node {
 	case *PrefixExpression:
 		node.Right, _ = Modify(node.Right, modifier).(Expression)
 
+	case *CallExpression:
+		node.Function, _ = Modify(node.Function, modifier).(Expression)
+		for i, arg := range node.Arguments {
+			node.Arguments[i], _ = Modify(arg, modifier).(Expression)
+		}
+
 	case *IndexExpression:
 		node.Left, _ = Modify(node.Left, modifier).(Expression)
 		node.Index, _ = Modify(node.Index, modifier).(Expression)
 
+	case *SliceExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		if node.Start != nil {
+			node.Start, _ = Modify(node.Start, modifier).(Expression)
+		}
+		if node.Stop != nil {
+			node.Stop, _ = Modify(node.Stop, modifier).(Expression)
+		}
+		if node.Step != nil {
+			node.Step, _ = Modify(node.Step, modifier).(Expression)
+		}
+
 	case *IfExpression:
 		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
 		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
@@ -45,21 +63,68 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		for i, param := range node.Parameters {
 			node.Parameters[i], _ = Modify(param, modifier).(*Identifier)
 		}
+		for i, def := range node.Defaults {
+			if def != nil {
+				node.Defaults[i], _ = Modify(def, modifier).(Expression)
+			}
+		}
+		if node.Rest != nil {
+			node.Rest, _ = Modify(node.Rest, modifier).(*Identifier)
+		}
 		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
 
+	case *InterpolatedString:
+		for i, part := range node.Parts {
+			node.Parts[i], _ = Modify(part, modifier).(Expression)
+		}
+
 	case *ArrayLiteral:
 		for i, element := range node.Elements {
 			node.Elements[i], _ = Modify(element, modifier).(Expression)
 		}
 
+	case *WhileStatement:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *ForStatement:
+		node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *TryExpression:
+		node.Block, _ = Modify(node.Block, modifier).(*BlockStatement)
+		if node.Catch != nil {
+			node.Param, _ = Modify(node.Param, modifier).(*Identifier)
+			node.Catch, _ = Modify(node.Catch, modifier).(*BlockStatement)
+		}
+		if node.Finally != nil {
+			node.Finally, _ = Modify(node.Finally, modifier).(*BlockStatement)
+		}
+
+	case *AssignExpression:
+		node.Target, _ = Modify(node.Target, modifier).(Expression)
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *SpreadExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *ThrowExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *MemberExpression:
+		node.Object, _ = Modify(node.Object, modifier).(Expression)
+
 	case *HashLiteral:
 		newPairs := make(map[Expression]Expression)
-		for key, val := range node.Pairs {
+		newKeys := make([]Expression, len(node.Keys))
+		for i, key := range node.Keys {
 			newKey, _ := Modify(key, modifier).(Expression)
-			newVal, _ := Modify(val, modifier).(Expression)
+			newVal, _ := Modify(node.Pairs[key], modifier).(Expression)
 			newPairs[newKey] = newVal
+			newKeys[i] = newKey
 		}
 		node.Pairs = newPairs
+		node.Keys = newKeys
 	}
 
 	return modifier(node)
@@ -111,6 +111,88 @@ func TestModify(t *testing.T) {
 			&ArrayLiteral{Elements: []Expression{one(), one()}},
 			&ArrayLiteral{Elements: []Expression{two(), two()}},
 		},
+		{
+			&TryExpression{
+				Block: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+				Param: &Identifier{Value: "e"},
+				Catch: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+				Finally: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&TryExpression{
+				Block: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+				Param: &Identifier{Value: "e"},
+				Catch: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+				Finally: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&ForStatement{
+				Value:    &Identifier{Value: "x"},
+				Iterable: one(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&ForStatement{
+				Value:    &Identifier{Value: "x"},
+				Iterable: two(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&InterpolatedString{
+				Parts: []Expression{&StringLiteral{}, one(), &StringLiteral{}},
+			},
+			&InterpolatedString{
+				Parts: []Expression{&StringLiteral{}, two(), &StringLiteral{}},
+			},
+		},
+		{
+			&SliceExpression{Left: one(), Start: one(), Step: one()},
+			&SliceExpression{Left: two(), Start: two(), Step: two()},
+		},
+		{
+			&ThrowExpression{Value: one()},
+			&ThrowExpression{Value: two()},
+		},
+		{
+			&CallExpression{Function: one(), Arguments: []Expression{one(), one()}},
+			&CallExpression{Function: two(), Arguments: []Expression{two(), two()}},
+		},
+		{
+			&MemberExpression{Object: one(), Member: &Identifier{Value: "x"}},
+			&MemberExpression{Object: two(), Member: &Identifier{Value: "x"}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -123,11 +205,13 @@ func TestModify(t *testing.T) {
 	}
 
 	// test for hash literal
+	key1, key2 := one(), one()
 	hashLiteral := &HashLiteral{
 		Pairs: map[Expression]Expression{
-			one(): one(),
-			one(): one(),
+			key1: one(),
+			key2: one(),
 		},
+		Keys: []Expression{key1, key2},
 	}
 
 	Modify(hashLiteral, turnOneIntoTwo)
@@ -0,0 +1,145 @@
+package ast
+
+// Inspect traverses the tree rooted at node in depth-first order. It calls
+// f(node) and, if f returns true, inspects the children of node. Unlike
+// Modify, it also visits the names bound by let and for statements and
+// the alias of an import statement.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, statement := range node.Statements {
+			Inspect(statement, f)
+		}
+
+	case *ExpressionStatement:
+		inspectExpression(node.Expression, f)
+
+	case *InfixExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Right, f)
+
+	case *PrefixExpression:
+		inspectExpression(node.Right, f)
+
+	case *CallExpression:
+		inspectExpression(node.Function, f)
+		for _, arg := range node.Arguments {
+			inspectExpression(arg, f)
+		}
+
+	case *IndexExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Index, f)
+
+	case *SliceExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Start, f)
+		inspectExpression(node.Stop, f)
+		inspectExpression(node.Step, f)
+
+	case *IfExpression:
+		inspectExpression(node.Condition, f)
+		Inspect(node.Consequence, f)
+		if node.Alternative != nil {
+			Inspect(node.Alternative, f)
+		}
+
+	case *BlockStatement:
+		for _, statement := range node.Statements {
+			Inspect(statement, f)
+		}
+
+	case *ReturnStatement:
+		inspectExpression(node.ReturnValue, f)
+
+	case *LetStatement:
+		Inspect(node.Name, f)
+		inspectExpression(node.Value, f)
+
+	case *ImportStatement:
+		if node.Alias != nil {
+			Inspect(node.Alias, f)
+		}
+
+	case *FunctionLiteral:
+		for _, param := range node.Parameters {
+			Inspect(param, f)
+		}
+		for _, def := range node.Defaults {
+			inspectExpression(def, f)
+		}
+		if node.Rest != nil {
+			Inspect(node.Rest, f)
+		}
+		Inspect(node.Body, f)
+
+	case *MacroLiteral:
+		for _, param := range node.Parameters {
+			Inspect(param, f)
+		}
+		Inspect(node.Body, f)
+
+	case *InterpolatedString:
+		for _, part := range node.Parts {
+			inspectExpression(part, f)
+		}
+
+	case *ArrayLiteral:
+		for _, element := range node.Elements {
+			inspectExpression(element, f)
+		}
+
+	case *HashLiteral:
+		for _, key := range node.Keys {
+			inspectExpression(key, f)
+			inspectExpression(node.Pairs[key], f)
+		}
+
+	case *WhileStatement:
+		inspectExpression(node.Condition, f)
+		Inspect(node.Body, f)
+
+	case *ForStatement:
+		if node.Key != nil {
+			Inspect(node.Key, f)
+		}
+		Inspect(node.Value, f)
+		inspectExpression(node.Iterable, f)
+		Inspect(node.Body, f)
+
+	case *TryExpression:
+		Inspect(node.Block, f)
+		if node.Catch != nil {
+			Inspect(node.Param, f)
+			Inspect(node.Catch, f)
+		}
+		if node.Finally != nil {
+			Inspect(node.Finally, f)
+		}
+
+	case *AssignExpression:
+		inspectExpression(node.Target, f)
+		inspectExpression(node.Value, f)
+
+	case *SpreadExpression:
+		inspectExpression(node.Value, f)
+
+	case *ThrowExpression:
+		inspectExpression(node.Value, f)
+
+	case *MemberExpression:
+		inspectExpression(node.Object, f)
+	}
+}
+
+// inspectExpression inspects exp unless it is nil. A nil Expression
+// stored in an interface field is not a nil Node, so it is checked here.
+func inspectExpression(exp Expression, f func(Node) bool) {
+	if exp != nil {
+		Inspect(exp, f)
+	}
+}
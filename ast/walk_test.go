@@ -0,0 +1,61 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("f"),
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{ident("a")},
+					Defaults:   []Expression{nil},
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ForStatement{
+								Value:    ident("x"),
+								Iterable: ident("a"),
+								Body: &BlockStatement{
+									Statements: []Statement{
+										&ExpressionStatement{Expression: &FunctionLiteral{
+											Parameters: []*Identifier{ident("skipped")},
+											Body:       &BlockStatement{},
+										}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			&ExpressionStatement{
+				Expression: &CallExpression{
+					Function:  ident("f"),
+					Arguments: []Expression{&MemberExpression{Object: ident("m"), Member: ident("member")}},
+				},
+			},
+		},
+	}
+
+	var names []string
+	Inspect(program, func(node Node) bool {
+		switch node := node.(type) {
+		case *Identifier:
+			names = append(names, node.Value)
+		case *FunctionLiteral:
+			// do not descend into the inner function
+			return node.Parameters[0].Value != "skipped"
+		}
+		return true
+	})
+
+	expected := []string{"f", "a", "x", "a", "f", "m"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("wrong names. want=%v, got=%v", expected, names)
+	}
+}
@@ -0,0 +1,312 @@
+// Package code defines the bytecode that the compiler emits and the
+// virtual machine executes.
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpNone            // pushes the value of a statement that has none
+	OpNull
+	OpTrue
+	OpFalse
+	OpPop
+	OpPopN
+	OpNip // removes the value below the top
+	OpDup
+	OpDup2
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpLessEqual
+	OpGreaterEqual
+	OpMinus
+	OpBang
+	OpBitNot
+
+	OpJump
+	OpJumpNotTruthy
+	OpJumpFalsyOrPop  // &&: keeps a falsy value and jumps, or pops it
+	OpJumpTruthyOrPop // ||: keeps a truthy value and jumps, or pops it
+	OpJumpIfArg       // jumps if the call passed the parameter
+
+	OpGetGlobal
+	OpSetGlobal
+	OpAssignGlobal
+	OpGetLocal
+	OpSetLocal
+	OpAssignLocal
+	OpGetCell
+	OpSetCell
+	OpAssignCell
+	OpGetFree
+	OpAssignFree
+	OpCheckAssign // fails if the variable is not declared
+	OpUnset       // makes a local undeclared for a new block scope
+	OpMakeCell    // moves a local into a cell that closures can share
+	OpLoadCell    // pushes the cell of a local
+	OpLoadFree    // pushes the cell of a free variable
+
+	OpArray
+	OpHash
+	OpHashKey // checks that the key on the top is hashable
+	OpAppend
+	OpSpread
+	OpInterpolate
+	OpIndex
+	OpSlice
+	OpCheckIndex
+	OpIndexedValue
+	OpSetIndex
+	OpMember
+
+	OpClosure
+	OpCall
+	OpCallSpread
+	OpTailCall       // OpCall in tail position
+	OpTailCallSpread // OpCallSpread in tail position
+	OpReturnValue
+
+	OpIter
+	OpIterNext
+
+	OpSetupTry
+	OpPopTry
+	OpRaise
+	OpThrow
+	OpErrorToHash
+
+	OpImport
+	OpQuote
+)
+
+// Operands of OpSlice telling which bounds are on the stack.
+const (
+	SliceStart = 1 << iota
+	SliceStop
+	SliceStep
+)
+
+// Operands of OpIterNext telling what to push for each element.
+const (
+	IterElement = iota // the key of a hash or the value of others
+	IterPair           // the key and the value
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpNone:     {"OpNone", []int{}},
+	OpNull:     {"OpNull", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpPop:      {"OpPop", []int{}},
+	OpPopN:     {"OpPopN", []int{2}},
+	OpNip:      {"OpNip", []int{}},
+	OpDup:      {"OpDup", []int{}},
+	OpDup2:     {"OpDup2", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpBitAnd:       {"OpBitAnd", []int{}},
+	OpBitOr:        {"OpBitOr", []int{}},
+	OpBitXor:       {"OpBitXor", []int{}},
+	OpShiftLeft:    {"OpShiftLeft", []int{}},
+	OpShiftRight:   {"OpShiftRight", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpMinus:        {"OpMinus", []int{}},
+	OpBang:         {"OpBang", []int{}},
+	OpBitNot:       {"OpBitNot", []int{}},
+
+	OpJump:            {"OpJump", []int{2}},
+	OpJumpNotTruthy:   {"OpJumpNotTruthy", []int{2}},
+	OpJumpFalsyOrPop:  {"OpJumpFalsyOrPop", []int{2}},
+	OpJumpTruthyOrPop: {"OpJumpTruthyOrPop", []int{2}},
+	OpJumpIfArg:       {"OpJumpIfArg", []int{2, 2}},
+
+	OpGetGlobal:    {"OpGetGlobal", []int{2}},
+	OpSetGlobal:    {"OpSetGlobal", []int{2}},
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+	OpGetLocal:     {"OpGetLocal", []int{2}},
+	OpSetLocal:     {"OpSetLocal", []int{2}},
+	OpAssignLocal:  {"OpAssignLocal", []int{2}},
+	OpGetCell:      {"OpGetCell", []int{2}},
+	OpSetCell:      {"OpSetCell", []int{2}},
+	OpAssignCell:   {"OpAssignCell", []int{2}},
+	OpGetFree:      {"OpGetFree", []int{2}},
+	OpAssignFree:   {"OpAssignFree", []int{2}},
+	OpCheckAssign:  {"OpCheckAssign", []int{1, 2}},
+	OpUnset:        {"OpUnset", []int{2}},
+	OpMakeCell:     {"OpMakeCell", []int{2}},
+	OpLoadCell:     {"OpLoadCell", []int{2}},
+	OpLoadFree:     {"OpLoadFree", []int{2}},
+
+	OpArray:        {"OpArray", []int{2}},
+	OpHash:         {"OpHash", []int{2}},
+	OpHashKey:      {"OpHashKey", []int{}},
+	OpAppend:       {"OpAppend", []int{}},
+	OpSpread:       {"OpSpread", []int{}},
+	OpInterpolate:  {"OpInterpolate", []int{2}},
+	OpIndex:        {"OpIndex", []int{}},
+	OpSlice:        {"OpSlice", []int{1}},
+	OpCheckIndex:   {"OpCheckIndex", []int{}},
+	OpIndexedValue: {"OpIndexedValue", []int{}},
+	OpSetIndex:     {"OpSetIndex", []int{}},
+	OpMember:       {"OpMember", []int{2}},
+
+	OpClosure:        {"OpClosure", []int{2, 2}},
+	OpCall:           {"OpCall", []int{2}},
+	OpCallSpread:     {"OpCallSpread", []int{}},
+	OpTailCall:       {"OpTailCall", []int{2}},
+	OpTailCallSpread: {"OpTailCallSpread", []int{}},
+	OpReturnValue:    {"OpReturnValue", []int{}},
+
+	OpIter:     {"OpIter", []int{}},
+	OpIterNext: {"OpIterNext", []int{2, 1}},
+
+	OpSetupTry:    {"OpSetupTry", []int{2}},
+	OpPopTry:      {"OpPopTry", []int{}},
+	OpRaise:       {"OpRaise", []int{}},
+	OpThrow:       {"OpThrow", []int{}},
+	OpErrorToHash: {"OpErrorToHash", []int{}},
+
+	OpImport: {"OpImport", []int{2}},
+	OpQuote:  {"OpQuote", []int{2, 2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes an instruction. Operands are big-endian.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction and returns them
+// with the number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
@@ -0,0 +1,1036 @@
+// Package compiler lowers a program to the bytecode of the virtual machine.
+//
+// Every statement leaves exactly one value on the stack, which may be nil
+// like the value of a let statement in the evaluator. The compiler tracks
+// the depth of the stack so that break and continue can drop the values
+// of the expressions they interrupt.
+package compiler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/code"
+	"github.com/tatsuya4559/monkey/evaluator"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/token"
+)
+
+const COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+
+// CompiledFunction is the bytecode of a function or of the main program.
+type CompiledFunction struct {
+	Instructions code.Instructions
+	Constants    []object.Object // shared by the functions of a program
+	Positions    []Position      // source positions by instruction offset
+
+	Name      string
+	Literal   *ast.FunctionLiteral // nil for the main program
+	NumLocals int
+	NumParams int
+	MinParams int  // parameters without default values
+	Rest      bool // the slot after the parameters collects the rest
+	MaxStack  int  // stack slots needed above the locals
+	BodyStart int  // offset of the body after binding the parameters
+
+	Locals []string // names of the locals by slot
+	Free   []string // names of the free variables
+}
+
+func (cf *CompiledFunction) Type() object.ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Position is the position of the node that emitted the instructions from
+// Offset on.
+type Position struct {
+	Offset int
+	Pos    token.Position
+}
+
+// PosAt returns the source position of the instruction at offset.
+func (cf *CompiledFunction) PosAt(offset int) token.Position {
+	i := sort.Search(len(cf.Positions), func(i int) bool {
+		return cf.Positions[i].Offset > offset
+	})
+	if i == 0 {
+		return token.Position{}
+	}
+	return cf.Positions[i-1].Pos
+}
+
+type Compiler struct {
+	constants []object.Object
+	names     map[string]int // constant indices of global names
+	functions []*CompiledFunction
+	scope     *compilationScope
+}
+
+// compilationScope is the state of the function being compiled.
+type compilationScope struct {
+	outer        *compilationScope
+	instructions code.Instructions
+	positions    []Position
+	symbols      *SymbolTable
+	pos          token.Position // position of the node being compiled
+	depth        int
+	maxDepth     int
+	contexts     []*context
+}
+
+// context is a loop or a try expression around the code being compiled,
+// which break, continue and return have to leave.
+type context struct {
+	loop           bool
+	depth          int // stack depth of the loop between iterations
+	continueTarget int
+	breaks         []int // jumps to the end of the loop
+
+	finally *ast.BlockStatement
+	handler bool // an exception handler is active
+}
+
+func New() *Compiler {
+	return &Compiler{names: make(map[string]int)}
+}
+
+// Compile compiles program into the function that runs it.
+func Compile(program *ast.Program) (*CompiledFunction, error) {
+	return New().Compile(program)
+}
+
+func (c *Compiler) Compile(program *ast.Program) (*CompiledFunction, error) {
+	c.enterScope(NewSymbolTable(capturedNames(program)))
+
+	if len(program.Statements) == 0 {
+		c.emit(code.OpNone)
+	}
+	for i, stmt := range program.Statements {
+		if err := c.compile(stmt); err != nil {
+			return nil, err
+		}
+		if i < len(program.Statements)-1 {
+			c.emit(code.OpPop)
+		}
+	}
+	c.emit(code.OpReturnValue)
+
+	main, err := c.leaveScope()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fn := range c.functions {
+		fn.Constants = c.constants
+	}
+	return main, nil
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	saved := c.scope.pos
+	c.scope.pos = node.Pos()
+	err := c.compileNode(node)
+	c.scope.pos = saved
+	return err
+}
+
+func (c *Compiler) compileNode(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return c.compile(node.Expression)
+
+	case *ast.BlockStatement:
+		return c.compileBlock(node)
+
+	case *ast.LetStatement:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.define(c.scope.symbols.Define(node.Name.Value))
+		c.emit(code.OpNone)
+
+	case *ast.ReturnStatement:
+		depth := c.scope.depth
+		if err := c.compile(node.ReturnValue); err != nil {
+			return err
+		}
+		if err := c.leaveContexts(0); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+		c.scope.depth = depth + 1
+
+	case *ast.BreakStatement:
+		return c.compileJumpOut(true)
+
+	case *ast.ContinueStatement:
+		return c.compileJumpOut(false)
+
+	case *ast.WhileStatement:
+		return c.compileWhile(node)
+
+	case *ast.ForStatement:
+		return c.compileFor(node)
+
+	case *ast.ImportStatement:
+		c.emit(code.OpImport, c.addConstant(&object.Quote{Node: node}))
+		c.emit(code.OpNone)
+
+	case *ast.Identifier:
+		c.load(c.scope.symbols.Resolve(node.Value))
+
+	case *ast.IntegerLiteral:
+		var value object.Object = &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			value = object.NewInteger(node.Big)
+		}
+		c.emit(code.OpConstant, c.addConstant(value))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.PrefixExpression:
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := prefixOperators[node.Operator]
+		if !ok {
+			return c.errorf("unknown operator: %s", node.Operator)
+		}
+		c.emit(op)
+
+	case *ast.InfixExpression:
+		return c.compileInfix(node)
+
+	case *ast.IfExpression:
+		return c.compileIf(node)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node)
+
+	case *ast.MacroLiteral:
+		// macros are defined before the program runs
+		c.emit(code.OpNone)
+
+	case *ast.CallExpression:
+		return c.compileCall(node)
+
+	case *ast.ArrayLiteral:
+		if hasSpread(node.Elements) {
+			return c.compileSpreadList(node.Elements)
+		}
+		for _, el := range node.Elements {
+			if err := c.compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, key := range node.Keys {
+			if err := c.compile(key); err != nil {
+				return err
+			}
+			c.emit(code.OpHashKey)
+			if err := c.compile(node.Pairs[key]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Keys))
+
+	case *ast.IndexExpression:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.SliceExpression:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		flags := 0
+		for i, exp := range []ast.Expression{node.Start, node.Stop, node.Step} {
+			if exp == nil {
+				continue
+			}
+			if err := c.compile(exp); err != nil {
+				return err
+			}
+			flags |= 1 << i
+		}
+		c.emit(code.OpSlice, flags)
+
+	case *ast.MemberExpression:
+		if err := c.compile(node.Object); err != nil {
+			return err
+		}
+		c.emit(code.OpMember, c.addName(node.Member.Value))
+
+	case *ast.AssignExpression:
+		return c.compileAssign(node)
+
+	case *ast.TryExpression:
+		return c.compileTry(node)
+
+	case *ast.ThrowExpression:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	default:
+		// like the evaluator, a node without a value such as a spread
+		// expression out of a list evaluates to nil
+		c.emit(code.OpNone)
+	}
+
+	return nil
+}
+
+var prefixOperators = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+	"~": code.OpBitNot,
+}
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShiftLeft,
+	">>": code.OpShiftRight,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+func (c *Compiler) compileBlock(block *ast.BlockStatement) error {
+	if len(block.Statements) == 0 {
+		c.emit(code.OpNone)
+		return nil
+	}
+
+	for i, stmt := range block.Statements {
+		if err := c.compile(stmt); err != nil {
+			return err
+		}
+		if i < len(block.Statements)-1 {
+			c.emit(code.OpPop)
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileInfix(node *ast.InfixExpression) error {
+	if err := c.compile(node.Left); err != nil {
+		return err
+	}
+
+	if node.Operator == "&&" || node.Operator == "||" {
+		op := code.OpJumpFalsyOrPop
+		if node.Operator == "||" {
+			op = code.OpJumpTruthyOrPop
+		}
+		jump := c.emit(op, 0)
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.patchJump(jump)
+		return nil
+	}
+
+	if err := c.compile(node.Right); err != nil {
+		return err
+	}
+	op, ok := infixOperators[node.Operator]
+	if !ok {
+		return c.errorf("unknown operator: %s", node.Operator)
+	}
+	c.emit(op)
+	return nil
+}
+
+func (c *Compiler) compileIf(node *ast.IfExpression) error {
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 0)
+	depth := c.scope.depth
+
+	if err := c.compile(node.Consequence); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, 0)
+
+	c.patchJump(jumpNotTruthy)
+	c.scope.depth = depth
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compile(node.Alternative); err != nil {
+		return err
+	}
+	c.patchJump(jump)
+	return nil
+}
+
+// compileWhile keeps the value of the last iteration on the stack.
+func (c *Compiler) compileWhile(node *ast.WhileStatement) error {
+	c.emit(code.OpNone)
+
+	condition := len(c.scope.instructions)
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 0)
+
+	c.pushContext(&context{loop: true, depth: c.scope.depth, continueTarget: condition})
+	if err := c.compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpNip)
+	c.emit(code.OpJump, condition)
+	ctx := c.popContext()
+
+	c.patchJump(jumpNotTruthy)
+	for _, jump := range ctx.breaks {
+		c.patchJump(jump)
+	}
+	return nil
+}
+
+// compileFor keeps the iterator and the value of the last iteration on
+// the stack. Each iteration has a new scope as in the evaluator.
+func (c *Compiler) compileFor(node *ast.ForStatement) error {
+	if err := c.compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emitAt(node.Iterable.Pos(), code.OpIter)
+	c.emit(code.OpNone)
+	depth := c.scope.depth
+
+	next := len(c.scope.instructions)
+	mode := code.IterElement
+	if node.Key != nil {
+		mode = code.IterPair
+	}
+	iterNext := c.emit(code.OpIterNext, 0, mode)
+
+	symbols := c.scope.symbols
+	symbols.PushBlock()
+	var key Symbol
+	if node.Key != nil {
+		key = symbols.Declare(node.Key.Value)
+	}
+	value := symbols.Declare(node.Value.Value)
+	for _, name := range declaredNames(node.Body) {
+		symbols.Hoist(name)
+	}
+	c.resetBlock()
+
+	if node.Key != nil && node.Key.Value == node.Value.Value {
+		// the value is bound last in the evaluator
+		c.emit(code.OpNip)
+		c.define(value)
+	} else {
+		c.define(value)
+		if node.Key != nil {
+			c.define(key)
+		}
+	}
+
+	c.pushContext(&context{loop: true, depth: depth, continueTarget: next})
+	if err := c.compile(node.Body); err != nil {
+		return err
+	}
+	symbols.PopBlock()
+	c.emit(code.OpNip)
+	c.emit(code.OpJump, next)
+	ctx := c.popContext()
+
+	c.patchJump(iterNext)
+	for _, jump := range ctx.breaks {
+		c.patchJump(jump)
+	}
+	c.scope.depth = depth
+	c.emit(code.OpNip)
+	return nil
+}
+
+// resetBlock makes the variables of the innermost scope unbound, giving
+// new cells to those captured by closures.
+func (c *Compiler) resetBlock() {
+	for _, sym := range c.scope.symbols.BlockSymbols() {
+		c.emit(code.OpUnset, sym.Index)
+		if sym.Scope == CellScope {
+			c.emit(code.OpMakeCell, sym.Index)
+		}
+	}
+}
+
+// compileJumpOut compiles break or continue, which leave the try
+// expressions inside the innermost loop, running their finally blocks.
+func (c *Compiler) compileJumpOut(isBreak bool) error {
+	depth := c.scope.depth
+
+	loop := len(c.scope.contexts) - 1
+	for loop >= 0 && !c.scope.contexts[loop].loop {
+		loop--
+	}
+	if loop < 0 {
+		if isBreak {
+			return c.errorf("break outside loop")
+		}
+		return c.errorf("continue outside loop")
+	}
+
+	if n := c.scope.depth - c.scope.contexts[loop].depth; n > 0 {
+		c.emit(code.OpPopN, n)
+	}
+	if err := c.leaveContexts(loop + 1); err != nil {
+		return err
+	}
+
+	ctx := c.scope.contexts[loop]
+	if isBreak {
+		ctx.breaks = append(ctx.breaks, c.emit(code.OpJump, 0))
+	} else {
+		c.emit(code.OpJump, ctx.continueTarget)
+	}
+
+	c.scope.depth = depth + 1
+	return nil
+}
+
+// leaveContexts emits the code to leave the contexts from the innermost
+// one to contexts[n].
+func (c *Compiler) leaveContexts(n int) error {
+	contexts := c.scope.contexts
+	defer func() { c.scope.contexts = contexts }()
+
+	for i := len(contexts) - 1; i >= n; i-- {
+		ctx := contexts[i]
+		if ctx.handler {
+			c.emit(code.OpPopTry)
+		}
+		if ctx.finally != nil {
+			// a break in the finally block refers to the loops outside
+			c.scope.contexts = append([]*context(nil), contexts[:i]...)
+			if err := c.compile(ctx.finally); err != nil {
+				return err
+			}
+			c.emit(code.OpPop)
+		}
+	}
+	return nil
+}
+
+// compileTry compiles the finally block on every way out of the try
+// expression: after the block or the catch clause, on a jump out of them
+// and on an error that is not caught.
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	depth := c.scope.depth
+
+	setup := c.emit(code.OpSetupTry, 0)
+	c.pushContext(&context{finally: node.Finally, handler: true})
+	if err := c.compile(node.Block); err != nil {
+		return err
+	}
+	c.popContext()
+	c.emit(code.OpPopTry)
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	ends := []int{c.emit(code.OpJump, 0)}
+
+	// the handler finds the error on the stack
+	c.patchJump(setup)
+	c.scope.depth = depth + 1
+
+	if node.Catch != nil {
+		c.emit(code.OpErrorToHash)
+
+		symbols := c.scope.symbols
+		symbols.PushBlock()
+		param := symbols.Declare(node.Param.Value)
+		for _, name := range declaredNames(node.Catch) {
+			symbols.Hoist(name)
+		}
+		c.resetBlock()
+		c.define(param)
+
+		setup := -1
+		if node.Finally != nil {
+			setup = c.emit(code.OpSetupTry, 0)
+			c.pushContext(&context{finally: node.Finally, handler: true})
+		}
+		if err := c.compile(node.Catch); err != nil {
+			return err
+		}
+		symbols.PopBlock()
+
+		if node.Finally != nil {
+			c.popContext()
+			c.emit(code.OpPopTry)
+			if err := c.compileFinally(node.Finally); err != nil {
+				return err
+			}
+			ends = append(ends, c.emit(code.OpJump, 0))
+
+			c.patchJump(setup)
+			c.scope.depth = depth + 1
+			if err := c.compileFinally(node.Finally); err != nil {
+				return err
+			}
+			c.emit(code.OpRaise)
+		}
+	} else {
+		if err := c.compileFinally(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpRaise)
+	}
+
+	for _, end := range ends {
+		c.patchJump(end)
+	}
+	c.scope.depth = depth + 1
+	return nil
+}
+
+// compileFinally runs a finally block for its effects, if there is one.
+func (c *Compiler) compileFinally(finally *ast.BlockStatement) error {
+	if finally == nil {
+		return nil
+	}
+	if err := c.compile(finally); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	return nil
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral) error {
+	nodes := []ast.Node{node.Body}
+	for _, def := range node.Defaults {
+		if def != nil {
+			nodes = append(nodes, def)
+		}
+	}
+	symbols := NewEnclosedSymbolTable(c.scope.symbols, capturedNames(nodes...))
+	c.enterScope(symbols)
+
+	symbols.PushBlock()
+	params := make([]Symbol, len(node.Parameters))
+	for i, param := range node.Parameters {
+		params[i] = symbols.Declare(param.Value)
+	}
+	if node.Rest != nil {
+		symbols.Declare(node.Rest.Value)
+	}
+	for _, name := range declaredNames(nodes...) {
+		symbols.Hoist(name)
+	}
+	for _, sym := range symbols.BlockSymbols() {
+		if sym.Scope == CellScope {
+			c.emit(code.OpMakeCell, sym.Index)
+		}
+	}
+
+	minParams := len(node.Parameters)
+	for minParams > 0 && node.Defaults != nil && node.Defaults[minParams-1] != nil {
+		minParams--
+	}
+	for i, def := range node.Defaults {
+		if def == nil {
+			continue
+		}
+		skip := c.emit(code.OpJumpIfArg, i, 0)
+		if err := c.compile(def); err != nil {
+			return err
+		}
+		c.define(params[i])
+		c.patchJump(skip)
+	}
+	bodyStart := len(c.scope.instructions)
+
+	if err := c.compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	fn, err := c.leaveScope()
+	if err != nil {
+		return err
+	}
+	fn.Name = node.Name
+	fn.Literal = node
+	fn.NumParams = len(node.Parameters)
+	fn.MinParams = minParams
+	fn.Rest = node.Rest != nil
+	fn.BodyStart = bodyStart
+
+	captures := symbols.Captures()
+	for _, sym := range captures {
+		if sym.Scope == FreeScope {
+			c.emit(code.OpLoadFree, sym.Index)
+		} else {
+			c.emit(code.OpLoadCell, sym.Index)
+		}
+	}
+	c.emit(code.OpClosure, c.addConstant(fn), len(captures))
+	return nil
+}
+
+func (c *Compiler) compileCall(node *ast.CallExpression) error {
+	if node.Function.TokenLiteral() == "quote" {
+		return c.compileQuote(node)
+	}
+
+	if err := c.compile(node.Function); err != nil {
+		return err
+	}
+
+	if hasSpread(node.Arguments) {
+		if err := c.compileSpreadList(node.Arguments); err != nil {
+			return err
+		}
+		if node.Tail {
+			c.emit(code.OpTailCallSpread)
+		} else {
+			c.emit(code.OpCallSpread)
+		}
+		return nil
+	}
+
+	for _, arg := range node.Arguments {
+		if err := c.compile(arg); err != nil {
+			return err
+		}
+	}
+	if node.Tail {
+		c.emit(code.OpTailCall, len(node.Arguments))
+	} else {
+		c.emit(code.OpCall, len(node.Arguments))
+	}
+	return nil
+}
+
+// compileQuote evaluates the arguments of the unquote calls, which the
+// virtual machine puts into the quoted node.
+func (c *Compiler) compileQuote(node *ast.CallExpression) error {
+	var args []ast.Expression
+	if len(node.Arguments) == 1 {
+		args = evaluator.UnquoteArguments(node.Arguments[0])
+	}
+	for _, arg := range args {
+		if err := c.compile(arg); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpQuote, c.addConstant(&object.Quote{Node: node}), len(args))
+	return nil
+}
+
+// compileSpreadList builds an array from a list with spread elements.
+func (c *Compiler) compileSpreadList(exprs []ast.Expression) error {
+	c.emit(code.OpArray, 0)
+	for _, exp := range exprs {
+		if spread, ok := exp.(*ast.SpreadExpression); ok {
+			if err := c.compile(spread.Value); err != nil {
+				return err
+			}
+			c.emitAt(spread.Pos(), code.OpSpread)
+			continue
+		}
+
+		if err := c.compile(exp); err != nil {
+			return err
+		}
+		c.emit(code.OpAppend)
+	}
+	return nil
+}
+
+func hasSpread(exprs []ast.Expression) bool {
+	for _, exp := range exprs {
+		if _, ok := exp.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileAssign checks the target before evaluating the value, as the
+// evaluator does.
+func (c *Compiler) compileAssign(node *ast.AssignExpression) error {
+	compound := node.Operator != "="
+	var op code.Opcode
+	if compound {
+		var ok bool
+		op, ok = infixOperators[node.Operator[:len(node.Operator)-1]]
+		if !ok {
+			return c.errorf("unknown operator: %s", node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		sym := c.scope.symbols.Resolve(target.Value)
+		c.emit(code.OpCheckAssign, int(sym.Scope), c.symbolIndex(sym))
+		if compound {
+			c.load(sym)
+		}
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		c.emit(code.OpDup)
+		c.assign(sym)
+
+	case *ast.IndexExpression:
+		if err := c.compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.compile(target.Index); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(code.OpDup2)
+			c.emit(code.OpIndexedValue)
+		} else {
+			c.emit(code.OpCheckIndex)
+		}
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		c.emit(code.OpSetIndex)
+
+	default:
+		return c.errorf("invalid assignment target: %s", node.Target)
+	}
+	return nil
+}
+
+func (c *Compiler) load(sym Symbol) {
+	switch sym.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, c.addName(sym.Name))
+	case LocalScope:
+		c.emit(code.OpGetLocal, sym.Index)
+	case CellScope:
+		c.emit(code.OpGetCell, sym.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, sym.Index)
+	}
+}
+
+// define binds the value on the top to sym declared by let.
+func (c *Compiler) define(sym Symbol) {
+	switch sym.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, c.addName(sym.Name))
+	case LocalScope:
+		c.emit(code.OpSetLocal, sym.Index)
+	case CellScope:
+		c.emit(code.OpSetCell, sym.Index)
+	}
+}
+
+// assign updates the binding sym refers to with the value on the top.
+func (c *Compiler) assign(sym Symbol) {
+	switch sym.Scope {
+	case GlobalScope:
+		c.emit(code.OpAssignGlobal, c.addName(sym.Name))
+	case LocalScope:
+		c.emit(code.OpAssignLocal, sym.Index)
+	case CellScope:
+		c.emit(code.OpAssignCell, sym.Index)
+	case FreeScope:
+		c.emit(code.OpAssignFree, sym.Index)
+	}
+}
+
+// symbolIndex returns the operand that identifies sym in its scope.
+func (c *Compiler) symbolIndex(sym Symbol) int {
+	if sym.Scope == GlobalScope {
+		return c.addName(sym.Name)
+	}
+	return sym.Index
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// addName returns the constant index of a global name.
+func (c *Compiler) addName(name string) int {
+	if index, ok := c.names[name]; ok {
+		return index
+	}
+	index := c.addConstant(&object.String{Value: name})
+	c.names[name] = index
+	return index
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	scope := c.scope
+	pos := len(scope.instructions)
+
+	if n := len(scope.positions); n == 0 || scope.positions[n-1].Pos != scope.pos {
+		scope.positions = append(scope.positions, Position{Offset: pos, Pos: scope.pos})
+	}
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+
+	scope.depth += stackEffect(op, operands)
+	if scope.depth > scope.maxDepth {
+		scope.maxDepth = scope.depth
+	}
+	return pos
+}
+
+// emitAt emits an instruction that reports errors at pos.
+func (c *Compiler) emitAt(pos token.Position, op code.Opcode, operands ...int) int {
+	saved := c.scope.pos
+	c.scope.pos = pos
+	defer func() { c.scope.pos = saved }()
+	return c.emit(op, operands...)
+}
+
+// stackEffect returns how an instruction changes the depth of the stack
+// when it continues with the next instruction.
+func stackEffect(op code.Opcode, operands []int) int {
+	switch op {
+	case code.OpConstant, code.OpNone, code.OpNull, code.OpTrue, code.OpFalse,
+		code.OpDup, code.OpGetGlobal, code.OpGetLocal, code.OpGetCell,
+		code.OpGetFree, code.OpLoadCell, code.OpLoadFree:
+		return 1
+	case code.OpDup2:
+		return 2
+	case code.OpPopN:
+		return -operands[0]
+	case code.OpPop, code.OpNip,
+		code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+		code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft,
+		code.OpShiftRight, code.OpEqual, code.OpNotEqual, code.OpLessThan,
+		code.OpGreaterThan, code.OpLessEqual, code.OpGreaterEqual,
+		code.OpJumpNotTruthy, code.OpJumpFalsyOrPop, code.OpJumpTruthyOrPop,
+		code.OpSetGlobal, code.OpAssignGlobal, code.OpSetLocal,
+		code.OpAssignLocal, code.OpSetCell, code.OpAssignCell, code.OpAssignFree,
+		code.OpAppend, code.OpSpread, code.OpIndex, code.OpIndexedValue,
+		code.OpCallSpread, code.OpTailCallSpread, code.OpReturnValue, code.OpRaise:
+		return -1
+	case code.OpArray, code.OpInterpolate:
+		return 1 - operands[0]
+	case code.OpHash:
+		return 1 - 2*operands[0]
+	case code.OpSlice:
+		n := 0
+		for flags := operands[0]; flags != 0; flags >>= 1 {
+			n += flags & 1
+		}
+		return -n
+	case code.OpSetIndex:
+		return -2
+	case code.OpClosure:
+		return 1 - operands[1]
+	case code.OpCall, code.OpTailCall:
+		return -operands[0]
+	case code.OpIterNext:
+		if operands[1] == code.IterPair {
+			return 2
+		}
+		return 1
+	case code.OpQuote:
+		return 1 - operands[1]
+	default:
+		return 0
+	}
+}
+
+// patchJump makes the jump at pos go to the next instruction.
+func (c *Compiler) patchJump(pos int) {
+	ins := c.scope.instructions
+	offset := pos + 1
+	if code.Opcode(ins[pos]) == code.OpJumpIfArg {
+		offset += 2
+	}
+	binary.BigEndian.PutUint16(ins[offset:], uint16(len(ins)))
+}
+
+func (c *Compiler) pushContext(ctx *context) {
+	c.scope.contexts = append(c.scope.contexts, ctx)
+}
+
+func (c *Compiler) popContext() *context {
+	n := len(c.scope.contexts)
+	ctx := c.scope.contexts[n-1]
+	c.scope.contexts = c.scope.contexts[:n-1]
+	return ctx
+}
+
+func (c *Compiler) enterScope(symbols *SymbolTable) {
+	c.scope = &compilationScope{outer: c.scope, symbols: symbols}
+}
+
+func (c *Compiler) leaveScope() (*CompiledFunction, error) {
+	scope := c.scope
+	c.scope = scope.outer
+
+	if len(scope.instructions) > math.MaxUint16 {
+		return nil, fmt.Errorf("%s: function too large", scope.pos)
+	}
+
+	fn := &CompiledFunction{
+		Instructions: scope.instructions,
+		Positions:    scope.positions,
+		NumLocals:    scope.symbols.NumLocals(),
+		MaxStack:     scope.maxDepth,
+		Locals:       scope.symbols.Locals(),
+		Free:         scope.symbols.FreeNames(),
+	}
+	c.functions = append(c.functions, fn)
+	return fn, nil
+}
+
+func (c *Compiler) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("%s: %s", c.scope.pos, fmt.Sprintf(format, a...))
+}
@@ -0,0 +1,191 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/tatsuya4559/monkey/code"
+	"github.com/tatsuya4559/monkey/lexer"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/parser"
+)
+
+func testCompile(t *testing.T, input string) *CompiledFunction {
+	t.Helper()
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+
+	main, err := Compile(program)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	return main
+}
+
+func concatInstructions(s ...[]byte) code.Instructions {
+	var out code.Instructions
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func testInstructions(t *testing.T, expected, actual code.Instructions) {
+	t.Helper()
+	if actual.String() != expected.String() {
+		t.Errorf("wrong instructions.\nwant=\n%s\ngot=\n%s", expected, actual)
+	}
+}
+
+func TestCompileExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected code.Instructions
+	}{
+		{
+			"1 + 2",
+			concatInstructions(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"1; 2",
+			concatInstructions(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"",
+			concatInstructions(
+				code.Make(code.OpNone),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"if (true) { 1 }",
+			concatInstructions(
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"let x = 1; x",
+			concatInstructions(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpNone),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"[1, 2][0]",
+			concatInstructions(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpReturnValue),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		main := testCompile(t, tt.input)
+		testInstructions(t, tt.expected, main.Instructions)
+	}
+}
+
+func TestCompileConstants(t *testing.T) {
+	main := testCompile(t, `let a = 1; a + a; fn() { a }`)
+
+	// every use of a name shares a constant
+	if len(main.Constants) != 3 {
+		t.Fatalf("wrong number of constants. want=3, got=%d", len(main.Constants))
+	}
+	str, ok := main.Constants[1].(*object.String)
+	if !ok || str.Value != "a" {
+		t.Errorf("wrong constant. got=%T (%+v)", main.Constants[1], main.Constants[1])
+	}
+	fn := main.Constants[2].(*CompiledFunction)
+	if len(fn.Constants) != len(main.Constants) {
+		t.Errorf("functions do not share the constant pool")
+	}
+}
+
+func TestCompileClosures(t *testing.T) {
+	main := testCompile(t, "fn(a) { let b = a; fn() { b } }")
+
+	// functions are added to the constants once compiled, inner first
+	outer, ok := main.Constants[1].(*CompiledFunction)
+	if !ok {
+		t.Fatalf("constant is not CompiledFunction. got=%T", main.Constants[1])
+	}
+	if outer.NumParams != 1 || outer.NumLocals != 2 {
+		t.Errorf("wrong frame of outer function. params=%d, locals=%d",
+			outer.NumParams, outer.NumLocals)
+	}
+	testInstructions(t, concatInstructions(
+		code.Make(code.OpMakeCell, 1),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpSetCell, 1),
+		code.Make(code.OpNone),
+		code.Make(code.OpPop),
+		code.Make(code.OpLoadCell, 1),
+		code.Make(code.OpClosure, 0, 1),
+		code.Make(code.OpReturnValue),
+	), outer.Instructions)
+
+	inner := outer.Constants[0].(*CompiledFunction)
+	testInstructions(t, concatInstructions(
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpReturnValue),
+	), inner.Instructions)
+}
+
+func TestSymbolTableBeforeDeclaration(t *testing.T) {
+	global := NewSymbolTable(map[string]bool{"x": true})
+	global.PushBlock()
+	outer := global.Define("x")
+
+	local := NewEnclosedSymbolTable(global, map[string]bool{"x": true})
+	local.PushBlock()
+	local.Hoist("x")
+
+	// before the let statement, x is the variable of the enclosing
+	// function, but a nested function sees the local one
+	if sym := local.Resolve("x"); sym.Scope != FreeScope || sym.Index != 0 {
+		t.Errorf("wrong symbol before the declaration. got=%+v", sym)
+	}
+	if captures := local.Captures(); len(captures) != 1 || captures[0] != outer {
+		t.Errorf("wrong captures. got=%+v", captures)
+	}
+	nested := NewEnclosedSymbolTable(local, nil)
+	nested.PushBlock()
+	nested.Resolve("x")
+	if captures := nested.Captures(); len(captures) != 1 || captures[0].Scope != CellScope {
+		t.Errorf("wrong captures of the nested function. got=%+v", captures)
+	}
+
+	if sym := local.Define("x"); sym.Scope != CellScope || sym.Index != 0 {
+		t.Errorf("wrong symbol of the declaration. got=%+v", sym)
+	}
+	if sym := local.Resolve("x"); sym.Scope != CellScope || sym.Index != 0 {
+		t.Errorf("wrong symbol after the declaration. got=%+v", sym)
+	}
+}
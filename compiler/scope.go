@@ -0,0 +1,106 @@
+package compiler
+
+import "github.com/tatsuya4559/monkey/ast"
+
+// declaredNames returns the names bound by the let statements in the scope
+// of nodes, in order. Function bodies, for-in bodies and catch clauses
+// have scopes of their own; other blocks do not.
+func declaredNames(nodes ...ast.Node) []string {
+	var names []string
+
+	var visit func(ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			names = append(names, node.Name.Value)
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.ForStatement:
+			ast.Inspect(node.Iterable, visit)
+			return false
+		case *ast.TryExpression:
+			ast.Inspect(node.Block, visit)
+			if node.Finally != nil {
+				ast.Inspect(node.Finally, visit)
+			}
+			return false
+		}
+		return true
+	}
+
+	for _, node := range nodes {
+		ast.Inspect(node, visit)
+	}
+	return names
+}
+
+// capturedNames returns the names that the functions nested in nodes may
+// look up in an enclosing scope. It leaves out the variables that are
+// bound whenever their scope is active, such as loop variables, because
+// they never fall back to the enclosing scope.
+func capturedNames(nodes ...ast.Node) map[string]bool {
+	names := make(map[string]bool)
+
+	for _, node := range nodes {
+		ast.Inspect(node, func(node ast.Node) bool {
+			if fl, ok := node.(*ast.FunctionLiteral); ok {
+				addUsedNames(fl, names)
+				return false
+			}
+			return true
+		})
+	}
+	return names
+}
+
+// addUsedNames adds the names used in fl and in the functions nested in it.
+func addUsedNames(fl *ast.FunctionLiteral, names map[string]bool) {
+	if hasDefaults(fl) {
+		// a default value may refer to a later parameter, which is not
+		// bound yet
+		for _, param := range fl.Parameters {
+			names[param.Value] = true
+		}
+	}
+
+	var visit func(ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			names[node.Value] = true
+		case *ast.FunctionLiteral:
+			addUsedNames(node, names)
+			return false
+		case *ast.ForStatement:
+			ast.Inspect(node.Iterable, visit)
+			ast.Inspect(node.Body, visit)
+			return false
+		case *ast.TryExpression:
+			ast.Inspect(node.Block, visit)
+			if node.Catch != nil {
+				ast.Inspect(node.Catch, visit)
+			}
+			if node.Finally != nil {
+				ast.Inspect(node.Finally, visit)
+			}
+			return false
+		}
+		return true
+	}
+
+	for _, def := range fl.Defaults {
+		if def != nil {
+			ast.Inspect(def, visit)
+		}
+	}
+	ast.Inspect(fl.Body, visit)
+}
+
+func hasDefaults(fl *ast.FunctionLiteral) bool {
+	for _, def := range fl.Defaults {
+		if def != nil {
+			return true
+		}
+	}
+	return false
+}
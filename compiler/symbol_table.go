@@ -0,0 +1,165 @@
+package compiler
+
+type SymbolScope byte
+
+const (
+	GlobalScope SymbolScope = iota // bound in the environment by name
+	LocalScope                     // a slot of the frame
+	CellScope                      // a slot of the frame holding a cell
+	FreeScope                      // a cell captured by the closure
+)
+
+// Symbol tells where a variable is stored.
+//
+// Names resolve as in the resolver of the evaluator: a local variable is
+// bound from its declaration on, and before it its name refers to the
+// variable outside of its scope, except in the nested functions, which see
+// all the variables of the scope.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable resolves the names of a function, or of the main program
+// if Outer is nil.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	// blocks are the scopes with their own bindings: the function body,
+	// for-in iterations and catch clauses, innermost last. Names outside
+	// of blocks in the main program are global.
+	blocks []map[string]int
+	// declared are the names of each block declared so far
+	declared []map[string]bool
+	// captured are the names used by nested functions. Their locals
+	// are stored in cells.
+	captured map[string]bool
+
+	locals    []string // names of the locals by slot
+	free      []string // names of the free variables
+	freeOuter []Symbol // the symbols of Outer captured as free variables
+	freeIndex map[Symbol]int
+}
+
+func NewSymbolTable(captured map[string]bool) *SymbolTable {
+	return &SymbolTable{captured: captured, freeIndex: make(map[Symbol]int)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable, captured map[string]bool) *SymbolTable {
+	s := NewSymbolTable(captured)
+	s.Outer = outer
+	return s
+}
+
+// NumLocals returns the number of frame slots used so far.
+func (s *SymbolTable) NumLocals() int { return len(s.locals) }
+
+// PushBlock opens a scope with its own bindings.
+func (s *SymbolTable) PushBlock() {
+	s.blocks = append(s.blocks, make(map[string]int))
+	s.declared = append(s.declared, make(map[string]bool))
+}
+
+func (s *SymbolTable) PopBlock() {
+	s.blocks = s.blocks[:len(s.blocks)-1]
+	s.declared = s.declared[:len(s.declared)-1]
+}
+
+// BlockSymbols returns the symbols of the innermost scope by slot.
+func (s *SymbolTable) BlockSymbols() []Symbol {
+	block := s.blocks[len(s.blocks)-1]
+	symbols := make([]Symbol, 0, len(block))
+	for name, slot := range block {
+		symbols = append(symbols, s.slotSymbol(name, slot))
+	}
+	for i := 1; i < len(symbols); i++ {
+		for j := i; j > 0 && symbols[j].Index < symbols[j-1].Index; j-- {
+			symbols[j], symbols[j-1] = symbols[j-1], symbols[j]
+		}
+	}
+	return symbols
+}
+
+// Declare binds name to a new slot in the innermost scope, declared from
+// now on. Parameters are declared so that they take the slots of the
+// arguments.
+func (s *SymbolTable) Declare(name string) Symbol {
+	s.Hoist(name)
+	return s.Define(name)
+}
+
+// Hoist gives name a slot in the innermost scope unless it has one, for a
+// let statement of the scope that is not declared yet.
+func (s *SymbolTable) Hoist(name string) {
+	block := s.blocks[len(s.blocks)-1]
+	if _, ok := block[name]; !ok {
+		block[name] = len(s.locals)
+		s.locals = append(s.locals, name)
+	}
+}
+
+// Define returns the symbol a let statement binds in the innermost scope,
+// which is declared from now on.
+func (s *SymbolTable) Define(name string) Symbol {
+	if len(s.blocks) == 0 {
+		return Symbol{Name: name, Scope: GlobalScope}
+	}
+
+	s.Hoist(name)
+	s.declared[len(s.declared)-1][name] = true
+	return s.slotSymbol(name, s.blocks[len(s.blocks)-1][name])
+}
+
+func (s *SymbolTable) Resolve(name string) Symbol {
+	return s.resolve(name, false)
+}
+
+// Locals returns the names of the locals by slot.
+func (s *SymbolTable) Locals() []string { return s.locals }
+
+// FreeNames returns the names of the free variables.
+func (s *SymbolTable) FreeNames() []string { return s.free }
+
+// Captures returns the symbols of Outer that the free variables capture.
+func (s *SymbolTable) Captures() []Symbol { return s.freeOuter }
+
+// resolve looks name up from the innermost scope outwards, among all the
+// variables of the scopes if hoisted, or else the declared ones.
+func (s *SymbolTable) resolve(name string, hoisted bool) Symbol {
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if slot, ok := s.blocks[i][name]; ok && (hoisted || s.declared[i][name]) {
+			return s.slotSymbol(name, slot)
+		}
+	}
+
+	if s.Outer == nil {
+		return Symbol{Name: name, Scope: GlobalScope}
+	}
+	// a nested function runs once the scopes around it are set up
+	return s.capture(s.Outer.resolve(name, true))
+}
+
+// capture returns the symbol of a variable of Outer in this function.
+func (s *SymbolTable) capture(outer Symbol) Symbol {
+	if outer.Scope == GlobalScope {
+		return outer
+	}
+	if index, ok := s.freeIndex[outer]; ok {
+		return Symbol{Name: outer.Name, Scope: FreeScope, Index: index}
+	}
+
+	index := len(s.free)
+	s.freeIndex[outer] = index
+	s.freeOuter = append(s.freeOuter, outer)
+	s.free = append(s.free, outer.Name)
+
+	return Symbol{Name: outer.Name, Scope: FreeScope, Index: index}
+}
+
+func (s *SymbolTable) slotSymbol(name string, slot int) Symbol {
+	if s.captured[name] {
+		return Symbol{Name: name, Scope: CellScope, Index: slot}
+	}
+	return Symbol{Name: name, Scope: LocalScope, Index: slot}
+}
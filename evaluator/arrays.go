@@ -0,0 +1,411 @@
+package evaluator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/token"
+)
+
+// The array builtins call back into the evaluator, so they are registered
+// in init to avoid an initialization cycle with the builtins map.
+func init() {
+	for name, fn := range map[string]object.BuiltinFunction{
+		"map":      _map,
+		"filter":   _filter,
+		"reduce":   _reduce,
+		"sort":     _sort,
+		"reverse":  _reverse,
+		"concat":   _concat,
+		"contains": _contains,
+		"index_of": _indexOf,
+		"zip":      _zip,
+		"flatten":  _flatten,
+		"unique":   _unique,
+		"any":      _any,
+		"all":      _all,
+		"min":      _min,
+		"max":      _max,
+		"sum":      _sum,
+	} {
+		builtins[name] = &object.Builtin{Name: name, Fn: fn}
+	}
+}
+
+// callFunction calls a Monkey function or a builtin from a builtin. There
+// is no call site in the source, so the stack frame has no position.
+func callFunction(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, token.Position{})
+}
+
+// arrayArg returns the i-th argument of the builtin name as an array.
+func arrayArg(name string, args []object.Object, i int) ([]object.Object, *object.Error) {
+	arr, ok := args[i].(*object.Array)
+	if !ok {
+		return nil, newError(object.TYPE_ERROR, "%s to `%s` must be ARRAY, got %s",
+			argumentName(args, i), name, args[i].Type())
+	}
+	return arr.Elements, nil
+}
+
+func _map(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+	elements, err := arrayArg("map", args, 0)
+	if err != nil {
+		return err
+	}
+
+	mapped := make([]object.Object, len(elements))
+	for i, e := range elements {
+		result := callFunction(args[1], e)
+		if isError(result) {
+			return result
+		}
+		mapped[i] = result
+	}
+	return &object.Array{Elements: mapped}
+}
+
+func _filter(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+	elements, err := arrayArg("filter", args, 0)
+	if err != nil {
+		return err
+	}
+
+	filtered := []object.Object{}
+	for _, e := range elements {
+		result := callFunction(args[1], e)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			filtered = append(filtered, e)
+		}
+	}
+	return &object.Array{Elements: filtered}
+}
+
+// reduce(arr, initial, f) folds arr from the left with f(acc, element).
+func _reduce(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 3, 3); err != nil {
+		return err
+	}
+	elements, err := arrayArg("reduce", args, 0)
+	if err != nil {
+		return err
+	}
+
+	result := args[1]
+	for _, e := range elements {
+		result = callFunction(args[2], result, e)
+		if isError(result) {
+			return result
+		}
+	}
+	return result
+}
+
+// sort returns a sorted copy of an array. Without a comparator elements
+// are ordered by <. A comparator cmp(a, b) returns whether a goes before
+// b, or an integer that is negative if it does.
+func _sort(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+	elements, err := arrayArg("sort", args, 0)
+	if err != nil {
+		return err
+	}
+
+	less := func(a, b object.Object) object.Object {
+		return evalInfixExpression("<", a, b)
+	}
+	if len(args) == 2 {
+		less = func(a, b object.Object) object.Object {
+			return callFunction(args[1], a, b)
+		}
+	}
+
+	sorted := make([]object.Object, len(elements))
+	copy(sorted, elements)
+
+	var sortErr object.Object
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		result := less(sorted[i], sorted[j])
+		if isError(result) {
+			sortErr = result
+			return false
+		}
+		if integer, ok := result.(*object.Integer); ok {
+			return integer.Value < 0
+		}
+		return isTruthy(result)
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+
+	return &object.Array{Elements: sorted}
+}
+
+func _reverse(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return err
+	}
+	elements, err := arrayArg("reverse", args, 0)
+	if err != nil {
+		return err
+	}
+
+	reversed := make([]object.Object, len(elements))
+	for i, e := range elements {
+		reversed[len(elements)-1-i] = e
+	}
+	return &object.Array{Elements: reversed}
+}
+
+// concat joins any number of arrays into a new one.
+func _concat(args ...object.Object) object.Object {
+	joined := []object.Object{}
+	for i := range args {
+		elements, err := arrayArg("concat", args, i)
+		if err != nil {
+			return err
+		}
+		joined = append(joined, elements...)
+	}
+	return &object.Array{Elements: joined}
+}
+
+// indexInArray returns the index of the first element equal to obj, or -1.
+func indexInArray(elements []object.Object, obj object.Object) int {
+	for i, e := range elements {
+		if object.Equals(e, obj) {
+			return i
+		}
+	}
+	return -1
+}
+
+// contains reports whether an array has an element equal to the value,
+// or whether a string contains a substring.
+func _contains(args ...object.Object) object.Object {
+	if len(args) == 2 {
+		if arr, ok := args[0].(*object.Array); ok {
+			return nativeBoolToBooleanObject(indexInArray(arr.Elements, args[1]) >= 0)
+		}
+	}
+	return stringPredicate("contains", strings.Contains)(args...)
+}
+
+// index_of returns the index of the first element of an array equal to
+// the value, or the rune index of a substring in a string. It returns -1
+// if there is none.
+func _indexOf(args ...object.Object) object.Object {
+	if len(args) == 2 {
+		if arr, ok := args[0].(*object.Array); ok {
+			return &object.Integer{Value: int64(indexInArray(arr.Elements, args[1]))}
+		}
+	}
+	return _stringIndexOf(args...)
+}
+
+// zip returns an array of arrays, the i-th of which has the i-th element
+// of each argument. It is as long as the shortest argument.
+func _zip(args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=at least 1, got=%d",
+			len(args))
+	}
+
+	arrays := make([][]object.Object, len(args))
+	length := -1
+	for i := range args {
+		elements, err := arrayArg("zip", args, i)
+		if err != nil {
+			return err
+		}
+		arrays[i] = elements
+		if length < 0 || len(elements) < length {
+			length = len(elements)
+		}
+	}
+
+	zipped := make([]object.Object, length)
+	for i := range zipped {
+		tuple := make([]object.Object, len(arrays))
+		for j, elements := range arrays {
+			tuple[j] = elements[i]
+		}
+		zipped[i] = &object.Array{Elements: tuple}
+	}
+	return &object.Array{Elements: zipped}
+}
+
+// flatten flattens nested arrays, or only depth levels of them if given.
+func _flatten(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+	elements, err := arrayArg("flatten", args, 0)
+	if err != nil {
+		return err
+	}
+
+	depth := int64(-1)
+	if len(args) == 2 {
+		if depth, err = integerArg("flatten", args, 1); err != nil {
+			return err
+		}
+		if depth < 0 {
+			return newError(object.VALUE_ERROR, "negative flatten depth: %d", depth)
+		}
+	}
+
+	return &object.Array{Elements: flatten([]object.Object{}, elements, depth)}
+}
+
+// flatten appends elements to flat, expanding nested arrays up to depth
+// levels, or all of them if depth is negative.
+func flatten(flat, elements []object.Object, depth int64) []object.Object {
+	for _, e := range elements {
+		if arr, ok := e.(*object.Array); ok && depth != 0 {
+			flat = flatten(flat, arr.Elements, depth-1)
+		} else {
+			flat = append(flat, e)
+		}
+	}
+	return flat
+}
+
+// unique returns the elements of an array without duplicates, keeping
+// the first occurrence of each.
+func _unique(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return err
+	}
+	elements, err := arrayArg("unique", args, 0)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[object.HashKey]bool)
+	unique := []object.Object{}
+	for _, e := range elements {
+		if hashable, ok := e.(object.Hashable); ok {
+			key := hashable.HashKey()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		} else if indexInArray(unique, e) >= 0 {
+			continue
+		}
+		unique = append(unique, e)
+	}
+	return &object.Array{Elements: unique}
+}
+
+// anyOrAll returns a builtin that checks whether any or all elements of
+// an array are truthy, or satisfy a predicate if given.
+func anyOrAll(name string, want bool) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if err := checkArgCount(args, 1, 2); err != nil {
+			return err
+		}
+		elements, err := arrayArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range elements {
+			result := e
+			if len(args) == 2 {
+				result = callFunction(args[1], e)
+				if isError(result) {
+					return result
+				}
+			}
+			if isTruthy(result) == want {
+				return nativeBoolToBooleanObject(want)
+			}
+		}
+		return nativeBoolToBooleanObject(!want)
+	}
+}
+
+var (
+	_any = anyOrAll("any", true)
+	_all = anyOrAll("all", false)
+)
+
+// extremum returns a builtin that finds the smallest or largest of an
+// array, or of its arguments if there are several.
+func extremum(name, operator string) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) < 1 {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=at least 1, got=%d",
+				len(args))
+		}
+
+		elements := args
+		if len(args) == 1 {
+			var err *object.Error
+			if elements, err = arrayArg(name, args, 0); err != nil {
+				return err
+			}
+		}
+		if len(elements) == 0 {
+			return newError(object.VALUE_ERROR, "`%s` of empty array", name)
+		}
+
+		result := elements[0]
+		for _, e := range elements[1:] {
+			better := evalInfixExpression(operator, e, result)
+			if isError(better) {
+				return better
+			}
+			if better == TRUE {
+				result = e
+			}
+		}
+		return result
+	}
+}
+
+var (
+	_min = extremum("min", "<")
+	_max = extremum("max", ">")
+)
+
+// sum adds up the elements of an array, starting from 0 or start if given.
+func _sum(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+	elements, err := arrayArg("sum", args, 0)
+	if err != nil {
+		return err
+	}
+
+	var result object.Object = &object.Integer{Value: 0}
+	if len(args) == 2 {
+		result = args[1]
+	}
+	for _, e := range elements {
+		result = evalInfixExpression("+", result, e)
+		if isError(result) {
+			return result
+		}
+	}
+	return result
+}
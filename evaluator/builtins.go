@@ -2,43 +2,75 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/tatsuya4559/monkey/object"
 )
 
 var builtins = map[string]*object.Builtin{
-	"len":   {Fn: _len},
-	"first": {Fn: _first},
-	"last":  {Fn: _last},
-	"rest":  {Fn: _rest},
-	"push":  {Fn: _push},
-	"puts":  {Fn: _puts},
+	"len":   {Name: "len", Fn: _len},
+	"first": {Name: "first", Fn: _first},
+	"last":  {Name: "last", Fn: _last},
+	"rest":  {Name: "rest", Fn: _rest},
+	"push":  {Name: "push", Fn: _push},
+	"puts":  {Name: "puts", Fn: _puts},
+	"int":   {Name: "int", Fn: _int},
+	"float": {Name: "float", Fn: _float},
+	"range": {Name: "range", Fn: _range},
+
+	// strings
+	"slice":       {Name: "slice", Fn: _slice},
+	"split":       {Name: "split", Fn: _split},
+	"join":        {Name: "join", Fn: _join},
+	"trim":        {Name: "trim", Fn: _trim},
+	"upper":       {Name: "upper", Fn: _upper},
+	"lower":       {Name: "lower", Fn: _lower},
+	"replace":     {Name: "replace", Fn: _replace},
+	"starts_with": {Name: "starts_with", Fn: stringPredicate("starts_with", strings.HasPrefix)},
+	"ends_with":   {Name: "ends_with", Fn: stringPredicate("ends_with", strings.HasSuffix)},
+	"repeat":      {Name: "repeat", Fn: _repeat},
+	"chars":       {Name: "chars", Fn: _chars},
+	"format":      {Name: "format", Fn: _format},
+
+	// hashes
+	"keys":    {Name: "keys", Fn: _keys},
+	"values":  {Name: "values", Fn: _values},
+	"entries": {Name: "entries", Fn: _entries},
+	"has_key": {Name: "has_key", Fn: _hasKey},
+	"delete":  {Name: "delete", Fn: _delete},
+	"merge":   {Name: "merge", Fn: _merge},
 }
 
 func _len(args ...object.Object) object.Object {
 	if len(args) != 1 {
-		return newError("wrong number of arguments. want=1, got=%d", len(args))
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1, got=%d", len(args))
 	}
 
 	switch arg := args[0].(type) {
 	case *object.String:
-		return &object.Integer{Value: int64(len(arg.Value))}
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 	case *object.Array:
 		return &object.Integer{Value: int64(len(arg.Elements))}
 	case *object.Hash:
 		return &object.Integer{Value: int64(len(arg.Pairs))}
+	case *object.Range:
+		return object.NewInteger(new(big.Int).SetUint64(arg.Len()))
 	default:
-		return newError("argument to `len` not supported, got %s",
+		return newError(object.TYPE_ERROR, "argument to `len` not supported, got %s",
 			arg.Type())
 	}
 }
 
 func _first(args ...object.Object) object.Object {
 	if len(args) != 1 {
-		return newError("wrong number of arguments. want=1, got=%d", len(args))
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1, got=%d", len(args))
 	}
 	if args[0].Type() != object.ARRAY_OBJ {
-		return newError("argument to `first` must be ARRAY, got %s",
+		return newError(object.TYPE_ERROR, "argument to `first` must be ARRAY, got %s",
 			args[0].Type())
 	}
 
@@ -51,10 +83,10 @@ func _first(args ...object.Object) object.Object {
 
 func _last(args ...object.Object) object.Object {
 	if len(args) != 1 {
-		return newError("wrong number of arguments. want=1, got=%d", len(args))
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1, got=%d", len(args))
 	}
 	if args[0].Type() != object.ARRAY_OBJ {
-		return newError("argument to `last` must be ARRAY, got %s",
+		return newError(object.TYPE_ERROR, "argument to `last` must be ARRAY, got %s",
 			args[0].Type())
 	}
 
@@ -68,10 +100,10 @@ func _last(args ...object.Object) object.Object {
 
 func _rest(args ...object.Object) object.Object {
 	if len(args) != 1 {
-		return newError("wrong number of arguments. want=1, got=%d", len(args))
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1, got=%d", len(args))
 	}
 	if args[0].Type() != object.ARRAY_OBJ {
-		return newError("argument to `rest` must be ARRAY, got %s",
+		return newError(object.TYPE_ERROR, "argument to `rest` must be ARRAY, got %s",
 			args[0].Type())
 	}
 
@@ -87,10 +119,10 @@ func _rest(args ...object.Object) object.Object {
 
 func _push(args ...object.Object) object.Object {
 	if len(args) != 2 {
-		return newError("wrong number of arguments. want=2, got=%d", len(args))
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=2, got=%d", len(args))
 	}
 	if args[0].Type() != object.ARRAY_OBJ {
-		return newError("first argument to `push` must be ARRAY, got %s",
+		return newError(object.TYPE_ERROR, "first argument to `push` must be ARRAY, got %s",
 			args[0].Type())
 	}
 
@@ -111,3 +143,90 @@ func _puts(args ...object.Object) object.Object {
 
 	return NULL
 }
+
+func _int(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1, got=%d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInteger:
+		return arg
+	case *object.Float:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+			return newError(object.VALUE_ERROR, "cannot convert %s to INTEGER",
+				arg.Inspect())
+		}
+		value, _ := big.NewFloat(arg.Value).Int(nil)
+		return object.NewInteger(value)
+	case *object.String:
+		value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+		if !ok {
+			return newError(object.VALUE_ERROR, "could not parse %q as integer",
+				arg.Value)
+		}
+		return object.NewInteger(value)
+	default:
+		return newError(object.TYPE_ERROR, "argument to `int` not supported, got %s",
+			arg.Type())
+	}
+}
+
+func _float(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1, got=%d", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInteger:
+		return &object.Float{Value: toFloat(arg)}
+	case *object.Float:
+		return arg
+	case *object.String:
+		value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil {
+			return newError(object.VALUE_ERROR, "could not parse %q as float",
+				arg.Value)
+		}
+		return &object.Float{Value: value}
+	default:
+		return newError(object.TYPE_ERROR, "argument to `float` not supported, got %s",
+			arg.Type())
+	}
+}
+
+func _range(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=1 to 3, got=%d", len(args))
+	}
+
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case *object.Integer:
+			bounds[i] = arg.Value
+		case *object.BigInteger:
+			return newError(object.VALUE_ERROR, "argument to `range` out of range: %s",
+				arg.Inspect())
+		default:
+			return newError(object.TYPE_ERROR, "arguments to `range` must be INTEGER, got %s",
+				arg.Type())
+		}
+	}
+
+	r := &object.Range{Step: 1}
+	switch len(bounds) {
+	case 1:
+		r.Stop = bounds[0]
+	case 2:
+		r.Start, r.Stop = bounds[0], bounds[1]
+	case 3:
+		r.Start, r.Stop, r.Step = bounds[0], bounds[1], bounds[2]
+	}
+
+	if r.Step == 0 {
+		return newError(object.VALUE_ERROR, "range step must not be zero")
+	}
+
+	return r
+}
@@ -2,15 +2,21 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
 
 	"github.com/tatsuya4559/monkey/ast"
 	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/token"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func isError(obj object.Object) bool {
@@ -21,8 +27,21 @@ func isError(obj object.Object) bool {
 }
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+
+	// the innermost node that produced an error tells where it happened
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
+		if err := resolve(node, env); err != nil {
+			return err
+		}
 		return evalProgram(node, env)
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
@@ -33,6 +52,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -54,17 +77,34 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		define(node.Name, val, env)
 	case *ast.WhileStatement:
 		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return object.NewInteger(node.Big)
+		}
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.Boolean:
@@ -72,10 +112,22 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{
+			Name:       node.Name,
+			Parameters: params,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Body:       body,
+			Env:        env,
+			Pos:        node.Pos(),
+		}
 	case *ast.CallExpression:
 		if node.Function.TokenLiteral() == "quote" {
 			// quote allows only one argument
+			if len(node.Arguments) != 1 {
+				return newError(object.ARGUMENT_ERROR,
+					"wrong number of arguments. want=1, got=%d", len(node.Arguments))
+			}
 			return quote(node.Arguments[0], env)
 		}
 		function := Eval(node.Function, env)
@@ -86,7 +138,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		if fn, ok := function.(*object.Function); ok && node.Tail {
+			return &tailCall{fn: fn, args: args, pos: node.Pos()}
+		}
+		return applyFunction(function, args, node.Pos())
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -103,8 +158,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.ThrowExpression:
+		return evalThrowExpression(node, env)
 	}
 
 	return nil
@@ -133,17 +196,30 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
 
-		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-				return result
-			}
+		if interruptsBlock(result) {
+			return result
 		}
 	}
 
 	return result
 }
 
+// interruptsBlock reports whether obj stops the evaluation of the
+// rest of a block: a return, an error, a break or a continue.
+func interruptsBlock(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+
+	switch obj.Type() {
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ,
+		object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	default:
+		return false
+	}
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -157,8 +233,10 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalBitNotPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -176,12 +254,30 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", right.Type())
 	}
+}
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+func evalBitNotPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Not(right.Value))
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: ~%s", right.Type())
+	}
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
@@ -192,38 +288,148 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 		return nativeBoolToBooleanObject(!object.Equals(left, right))
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ:
+		return evalArrayInfixExpression(operator, left, right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s",
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s",
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	leftInt, leftOk := left.(*object.Integer)
+	rightInt, rightOk := right.(*object.Integer)
+	if !leftOk || !rightOk {
+		return evalBigIntegerInfixExpression(operator, left, right)
+	}
+	leftVal := leftInt.Value
+	rightVal := rightInt.Value
+
+	if (operator == "/" || operator == "%") && rightVal == 0 {
+		return newZeroDivisionError(operator)
+	}
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		if sum, ok := addInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: sum}
+		}
+		return evalBigIntegerInfixExpression(operator, left, right)
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		if diff, ok := subInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: diff}
+		}
+		return evalBigIntegerInfixExpression(operator, left, right)
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		if product, ok := mulInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: product}
+		}
+		return evalBigIntegerInfixExpression(operator, left, right)
 	case "/":
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "%":
 		return &object.Integer{Value: leftVal % rightVal}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<":
+		if rightVal < 0 {
+			return newNegativeShiftError(rightVal)
+		}
+		if shifted := leftVal << rightVal; shifted>>rightVal == leftVal {
+			return &object.Integer{Value: shifted}
+		}
+		return evalBigIntegerInfixExpression(operator, left, right)
+	case ">>":
+		if rightVal < 0 {
+			return newNegativeShiftError(rightVal)
+		}
+		return &object.Integer{Value: leftVal >> rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	default:
-		return newError("unknown operator: %s %s %s",
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+func newZeroDivisionError(operator string) *object.Error {
+	if operator == "%" {
+		return newError(object.ZERO_DIVISION_ERROR, "integer modulo by zero")
+	}
+	return newError(object.ZERO_DIVISION_ERROR, "integer division by zero")
+}
+
+func newNegativeShiftError(count int64) *object.Error {
+	return newError(object.VALUE_ERROR, "negative shift count: %d", count)
+}
+
+func isNumber(obj object.Object) bool {
+	t := obj.Type()
+	return t == object.INTEGER_OBJ || t == object.FLOAT_OBJ
+}
+
+// toFloat converts an INTEGER or FLOAT object into float64.
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInteger:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	default:
+		return math.NaN()
+	}
+}
+
+// evalFloatInfixExpression evaluates arithmetic on floats and on a mix of
+// integers and floats.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
@@ -238,12 +444,95 @@ func evalStringInfixExpression(
 	switch operator {
 	case "+":
 		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	default:
-		return newError("unknown operator: %s %s %s",
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
 
+// evalArrayInfixExpression compares arrays lexicographically. The first
+// pair of elements that are not equal decides the result, and a prefix
+// of an array is less than the array.
+func evalArrayInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftElems := left.(*object.Array).Elements
+	rightElems := right.(*object.Array).Elements
+
+	switch operator {
+	case "<", ">", "<=", ">=":
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+
+	for i := 0; i < len(leftElems) && i < len(rightElems); i++ {
+		if object.Equals(leftElems[i], rightElems[i]) {
+			continue
+		}
+		// the elements differ, so <= and >= are the same as < and >
+		return evalInfixExpression(operator[:1], leftElems[i], rightElems[i])
+	}
+
+	return evalIntegerInfixExpression(
+		operator,
+		&object.Integer{Value: int64(len(leftElems))},
+		&object.Integer{Value: int64(len(rightElems))},
+	)
+}
+
+// evalLogicalExpression evaluates && and || with short-circuiting. The
+// result is the operand that decides the value, not a boolean.
+func evalLogicalExpression(
+	node *ast.InfixExpression,
+	env *object.Environment,
+) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return left
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return left
+	}
+
+	return Eval(node.Right, env)
+}
+
+func evalInterpolatedString(
+	node *ast.InterpolatedString,
+	env *object.Environment,
+) object.Object {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		evaluated := Eval(part, env)
+		if isError(evaluated) {
+			return evaluated
+		}
+
+		if str, ok := evaluated.(*object.String); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString(evaluated.Inspect())
+		}
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalIfExpression(
 	ie *ast.IfExpression,
 	env *object.Environment,
@@ -288,23 +577,24 @@ func isTruthy(obj object.Object) bool {
 	return true
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
 }
 
 func evalIdentifier(
 	node *ast.Identifier,
 	env *object.Environment,
 ) object.Object {
-	if val, ok := env.Get(node.Value); ok {
+	if val, ok := lookup(node, env); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	// a local variable not bound yet does not fall back to the builtin
+	if builtin, ok := builtins[node.Value]; ok && node.Binding == nil {
 		return builtin
 	}
 
-	return newError("identifier not found: %s", node.Value)
+	return newError(object.NAME_ERROR, "identifier not found: %s", node.Value)
 }
 
 func evalExpressions(
@@ -314,6 +604,15 @@ func evalExpressions(
 	var result []object.Object
 
 	for _, e := range exprs {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			elements := evalSpreadExpression(spread, env)
+			if len(elements) == 1 && isError(elements[0]) {
+				return elements
+			}
+			result = append(result, elements...)
+			continue
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -324,33 +623,142 @@ func evalExpressions(
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func evalSpreadExpression(
+	spread *ast.SpreadExpression,
+	env *object.Environment,
+) []object.Object {
+	value := Eval(spread.Value, env)
+	if isError(value) {
+		return []object.Object{value}
+	}
+
+	array, ok := value.(*object.Array)
+	if !ok {
+		err := newError(object.TYPE_ERROR, "cannot spread %s", value.Type())
+		err.Pos = spread.Pos()
+		return []object.Object{err}
+	}
+	return array.Elements
+}
+
+// applyFunction calls fn with args. pos is the call site, which is recorded
+// in the stack trace of an error raised by a Monkey function.
+func applyFunction(
+	fn object.Object,
+	args []object.Object,
+	pos token.Position,
+) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		// the functions that made the tail calls applied here
+		var callers TailCallers
+		for {
+			if Profile != nil {
+				Profile.Enter(fn.Name, fn.Pos)
+			}
+			extendedEnv, err := extendFunctionEnv(fn, args)
+			if err != nil {
+				if Profile != nil {
+					Profile.Exit()
+				}
+				if callers.frames > 0 && !err.Pos.IsValid() {
+					err.Pos = pos
+				}
+				return callers.AppendTo(err)
+			}
+			evaluated := unwrapReturnValue(Eval(fn.Body, extendedEnv))
+			if Profile != nil {
+				Profile.Exit()
+			}
+
+			call, ok := evaluated.(*tailCall)
+			if !ok {
+				if err, ok := evaluated.(*object.Error); ok {
+					err.Trace = append(err.Trace, object.Frame{Function: fn.Name, Pos: pos})
+					return callers.AppendTo(err)
+				}
+				return evaluated
+			}
+			callers.Add(object.Frame{Function: fn.Name, Pos: pos})
+			fn, args, pos = call.fn, call.args, call.pos
+		}
 
 	case *object.Builtin:
+		if Profile != nil {
+			Profile.Enter(fn.Name, token.Position{})
+			defer Profile.Exit()
+		}
 		return fn.Fn(args...)
 
+	case Callable:
+		return fn.Call(args, pos)
+
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError(object.TYPE_ERROR, "not a function: %s", fn.Type())
 	}
-
 }
 
+// extendFunctionEnv binds args to the parameters of fn. Default values are
+// evaluated in the new environment, so they can refer to earlier parameters.
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
+) (*object.Environment, *object.Error) {
+	if err := checkArity(fn, len(args)); err != nil {
+		return nil, err
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for idx, param := range fn.Parameters {
-		env.Set(param.Value, args[idx])
+		if idx < len(args) {
+			define(param, args[idx], env)
+			continue
+		}
+
+		val := Eval(fn.Defaults[idx], env)
+		if err, ok := val.(*object.Error); ok {
+			return nil, err
+		}
+		define(param, val, env)
 	}
 
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		define(fn.Rest, &object.Array{Elements: rest}, env)
+	}
+
+	return env, nil
+}
+
+func checkArity(fn *object.Function, got int) *object.Error {
+	max := len(fn.Parameters)
+	min := max
+	for min > 0 && fn.Defaults != nil && fn.Defaults[min-1] != nil {
+		min--
+	}
+	return arityError(min, max, fn.Rest != nil, got)
+}
+
+// arityError returns an error if got arguments do not fit a function with
+// min to max parameters, or at least min if it has a rest parameter.
+func arityError(min, max int, rest bool, got int) *object.Error {
+	switch {
+	case rest && got < min:
+		return newError(object.ARGUMENT_ERROR,
+			"wrong number of arguments. want=at least %d, got=%d", min, got)
+	case !rest && min == max && got != max:
+		return newError(object.ARGUMENT_ERROR,
+			"wrong number of arguments. want=%d, got=%d", max, got)
+	case !rest && (got < min || got > max):
+		return newError(object.ARGUMENT_ERROR,
+			"wrong number of arguments. want=%d to %d, got=%d", min, max, got)
+	}
+
+	return nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -364,19 +772,29 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", left.Type())
 	}
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		// a BigInteger is always out of range
+		return NULL
+	}
+	idx := integer.Value
+	length := int64(len(arrayObject.Elements))
 
-	if idx < 0 || idx > max {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
 		return NULL
 	}
 	return arrayObject.Elements[idx]
@@ -386,9 +804,9 @@ func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
@@ -396,19 +814,18 @@ func evalHashLiteral(
 
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
@@ -416,7 +833,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
 	}
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
@@ -439,8 +856,9 @@ func evalWhileStatement(
 	}
 
 	for isTruthy(condition) {
-		result = Eval(ws.Body, env)
-		if isError(result) {
+		var done bool
+		result, done = evalLoopBody(ws.Body, env, result)
+		if done {
 			return result
 		}
 
@@ -452,3 +870,336 @@ func evalWhileStatement(
 
 	return result
 }
+
+// evalLoopBody evaluates one iteration of a loop body. prev is the
+// value of the previous iteration. It returns the value of the loop so
+// far and reports whether the loop is done.
+func evalLoopBody(
+	body *ast.BlockStatement,
+	env *object.Environment,
+	prev object.Object,
+) (object.Object, bool) {
+	evaluated := Eval(body, env)
+	if !interruptsBlock(evaluated) {
+		return evaluated, false
+	}
+
+	switch evaluated.Type() {
+	case object.BREAK_OBJ:
+		return prev, true
+	case object.CONTINUE_OBJ:
+		return prev, false
+	default:
+		return evaluated, true
+	}
+}
+
+func evalForStatement(
+	fs *ast.ForStatement,
+	env *object.Environment,
+) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var result object.Object
+	err := iterate(iterable, func(key, value object.Object) bool {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		if fs.Key != nil {
+			define(fs.Key, key, loopEnv)
+			define(fs.Value, value, loopEnv)
+		} else if iterable.Type() == object.HASH_OBJ {
+			define(fs.Value, key, loopEnv)
+		} else {
+			define(fs.Value, value, loopEnv)
+		}
+
+		var done bool
+		result, done = evalLoopBody(fs.Body, loopEnv, result)
+		return !done
+	})
+	if err != nil {
+		err.Pos = fs.Iterable.Pos()
+		return err
+	}
+
+	return result
+}
+
+// iterate calls fn with each key and value of obj until fn returns
+// false. Keys are indices for arrays, strings and ranges.
+func iterate(obj object.Object, fn func(key, value object.Object) bool) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Array:
+		for i := 0; i < len(obj.Elements); i++ {
+			if !fn(&object.Integer{Value: int64(i)}, obj.Elements[i]) {
+				break
+			}
+		}
+	case *object.Hash:
+		for _, pair := range obj.OrderedPairs() {
+			if !fn(pair.Key, pair.Value) {
+				break
+			}
+		}
+	case *object.String:
+		i := int64(0)
+		for _, r := range obj.Value {
+			if !fn(&object.Integer{Value: i}, &object.String{Value: string(r)}) {
+				break
+			}
+			i++
+		}
+	case *object.Range:
+		n := obj.Len()
+		for i := uint64(0); i < n; i++ {
+			key := object.NewInteger(new(big.Int).SetUint64(i))
+			if !fn(key, &object.Integer{Value: obj.At(i)}) {
+				break
+			}
+		}
+	default:
+		return newError(object.TYPE_ERROR, "cannot iterate over %s", obj.Type())
+	}
+
+	return nil
+}
+
+func evalTryExpression(
+	te *ast.TryExpression,
+	env *object.Environment,
+) object.Object {
+	result := Eval(te.Block, env)
+
+	if err, ok := result.(*object.Error); ok && te.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		define(te.Param, errorToHash(err), catchEnv)
+		result = Eval(te.Catch, catchEnv)
+	}
+
+	if te.Finally != nil {
+		finally := Eval(te.Finally, env)
+		if interruptsBlock(finally) {
+			return finally
+		}
+	}
+
+	return result
+}
+
+// errorToHash converts a caught error into a hash so that the Monkey code
+// can inspect it.
+func errorToHash(err *object.Error) *object.Hash {
+	fields := []struct {
+		key   string
+		value object.Object
+	}{
+		{"kind", &object.String{Value: string(err.Kind)}},
+		{"message", &object.String{Value: err.Message}},
+		{"file", &object.String{Value: err.Pos.Filename}},
+		{"line", &object.Integer{Value: int64(err.Pos.Line)}},
+		{"column", &object.Integer{Value: int64(err.Pos.Column)}},
+	}
+
+	hash := object.NewHash()
+	for _, f := range fields {
+		key := &object.String{Value: f.key}
+		hash.Set(key.HashKey(), object.HashPair{Key: key, Value: f.value})
+	}
+
+	return hash
+}
+
+// evalThrowExpression raises an error from a string, a hash with "message"
+// and optional "kind" keys such as a caught error, or any other value.
+func evalThrowExpression(
+	te *ast.ThrowExpression,
+	env *object.Environment,
+) object.Object {
+	val := Eval(te.Value, env)
+	if isError(val) {
+		return val
+	}
+	return throwValue(val)
+}
+
+// throwValue converts a thrown value into an error.
+func throwValue(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.String:
+		return newError(object.USER_ERROR, "%s", val.Value)
+	case *object.Hash:
+		kind := object.ErrorKind(object.USER_ERROR)
+		if s, ok := hashGet(val, "kind").(*object.String); ok {
+			kind = object.ErrorKind(s.Value)
+		}
+		message := val.Inspect()
+		if msg := hashGet(val, "message"); msg != nil {
+			message = msg.Inspect()
+		}
+		return newError(kind, "%s", message)
+	default:
+		return newError(object.USER_ERROR, "%s", val.Inspect())
+	}
+}
+
+func hashGet(hash *object.Hash, key string) object.Object {
+	pair, ok := hash.Pairs[(&object.String{Value: key}).HashKey()]
+	if !ok {
+		return nil
+	}
+	return pair.Value
+}
+
+func evalAssignExpression(
+	ae *ast.AssignExpression,
+	env *object.Environment,
+) object.Object {
+	switch target := ae.Target.(type) {
+	case *ast.Identifier:
+		return evalIdentifierAssignment(ae, target, env)
+	case *ast.IndexExpression:
+		return evalIndexAssignment(ae, target, env)
+	default:
+		return newError(object.TYPE_ERROR, "invalid assignment target: %s", ae.Target)
+	}
+}
+
+// evalAssignedValue evaluates the right hand side of ae and, for a compound
+// assignment, applies the operator to the current value.
+func evalAssignedValue(
+	ae *ast.AssignExpression,
+	current object.Object,
+	env *object.Environment,
+) object.Object {
+	val := Eval(ae.Value, env)
+	if isError(val) || ae.Operator == "=" {
+		return val
+	}
+
+	operator := strings.TrimSuffix(ae.Operator, "=")
+	return evalInfixExpression(operator, current, val)
+}
+
+func evalIdentifierAssignment(
+	ae *ast.AssignExpression,
+	ident *ast.Identifier,
+	env *object.Environment,
+) object.Object {
+	current, ok := lookup(ident, env)
+	if !ok {
+		return newError(object.NAME_ERROR,
+			"assignment to undeclared variable: %s", ident.Value)
+	}
+
+	val := evalAssignedValue(ae, current, env)
+	if isError(val) {
+		return val
+	}
+
+	assign(ident, val, env)
+	return val
+}
+
+func evalIndexAssignment(
+	ae *ast.AssignExpression,
+	target *ast.IndexExpression,
+	env *object.Environment,
+) object.Object {
+	left := Eval(target.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := Eval(target.Index, env)
+	if isError(index) {
+		return index
+	}
+
+	var current object.Object
+	if ae.Operator != "=" {
+		current = indexedValue(left, index)
+		if isError(current) {
+			return current
+		}
+	} else if err := checkIndexAssignment(left, index); err != nil {
+		return err
+	}
+
+	val := evalAssignedValue(ae, current, env)
+	if isError(val) {
+		return val
+	}
+	setIndex(left, index, val)
+	return val
+}
+
+// checkIndexAssignment returns an error if left[index] cannot be assigned.
+func checkIndexAssignment(left, index object.Object) *object.Error {
+	switch left := left.(type) {
+	case *object.Array:
+		_, err := arrayIndex(left, index)
+		return err
+	case *object.Hash:
+		if _, ok := index.(object.Hashable); !ok {
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
+		}
+		return nil
+	default:
+		return newError(object.TYPE_ERROR,
+			"index assignment not supported: %s", left.Type())
+	}
+}
+
+// indexedValue returns the current value of left[index] for a compound
+// assignment. Unlike an index expression, a missing element is an error.
+func indexedValue(left, index object.Object) object.Object {
+	if err := checkIndexAssignment(left, index); err != nil {
+		return err
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		idx, _ := arrayIndex(left, index)
+		return left.Elements[idx]
+	default:
+		hash := left.(*object.Hash)
+		pair, ok := hash.Pairs[index.(object.Hashable).HashKey()]
+		if !ok {
+			return newError(object.KEY_ERROR, "key not found: %s", index.Inspect())
+		}
+		return pair.Value
+	}
+}
+
+// setIndex sets left[index] to val. The assignment must have been checked
+// with checkIndexAssignment.
+func setIndex(left, index, val object.Object) {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, _ := arrayIndex(left, index)
+		left.Elements[idx] = val
+	case *object.Hash:
+		hashed := index.(object.Hashable).HashKey()
+		left.Set(hashed, object.HashPair{Key: index, Value: val})
+	}
+}
+
+// arrayIndex returns the position of an element of array for assignment,
+// counting a negative index from the end.
+func arrayIndex(array *object.Array, index object.Object) (int64, *object.Error) {
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return 0, newError(object.TYPE_ERROR,
+			"array index must be INTEGER, got %s", index.Type())
+	}
+	idx := integer.Value
+	length := int64(len(array.Elements))
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return 0, newError(object.INDEX_ERROR, "index out of range: %d", integer.Value)
+	}
+	return idx, nil
+}
@@ -1,11 +1,16 @@
-package evaluator
+package evaluator_test
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/evaluator"
 	"github.com/tatsuya4559/monkey/lexer"
 	"github.com/tatsuya4559/monkey/object"
 	"github.com/tatsuya4559/monkey/parser"
+	"github.com/tatsuya4559/monkey/token"
+	"github.com/tatsuya4559/monkey/vm"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -36,7 +41,31 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+// testEval evaluates input with both engines and checks that they agree.
+// It returns the result of the evaluator.
 func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+	evaluated := testRun(t, evaluator.Eval, input)
+	run := testRun(t, vm.Run, input)
+
+	if inspect(evaluated) != inspect(run) {
+		t.Errorf("engines disagree on %q. eval=%s, vm=%s",
+			input, inspect(evaluated), inspect(run))
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		if vmErr, ok := run.(*object.Error); ok && errObj.StackTrace() != vmErr.StackTrace() {
+			t.Errorf("engines disagree on the stack trace of %q. eval=\n%svm=\n%s",
+				input, errObj.StackTrace(), vmErr.StackTrace())
+		}
+	}
+	return evaluated
+}
+
+func testRun(
+	t *testing.T,
+	engine func(ast.Node, *object.Environment) object.Object,
+	input string,
+) object.Object {
 	t.Helper()
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -46,7 +75,14 @@ func testEval(t *testing.T, input string) object.Object {
 	}
 	env := object.NewEnvironment()
 
-	return Eval(program, env)
+	return engine(program, env)
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return string(obj.Type()) + " " + obj.Inspect()
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -73,6 +109,14 @@ func TestEvalStringExpression(t *testing.T) {
 		{`"Hello world"`, "Hello world"},
 		{`"foo";`, "foo"},
 		{`"foo" + " " + "bar";`, "foo bar"},
+		{`"a\tb\n\"c\" \\ \${d}"`, "a\tb\n\"c\" \\ ${d}"},
+		{`"\u{65e5}\u{1F600}"`, "日😀"},
+		{"`raw \\n ${x}\nline`", "raw \\n ${x}\nline"},
+		{`let name = "Monkey"; "Hello ${name}!"`, "Hello Monkey!"},
+		{`"${1 + 2}${3.5}${true}${[1, "a"]}"`, "33.5true[1, a]"},
+		{`let h = {"k": "v"}; "${h["k"]} ${ {"a": 1}["a"] } {x}"`, "v 1 {x}"},
+		{`let n = "m"; "a${"b${n}c"}d"`, "abmcd"},
+		{`let f = fn(x) { "<${x}>" }; f(f("y"))`, "<<y>>"},
 	}
 
 	for _, tt := range tests {
@@ -141,6 +185,27 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{`{"foo": 1, "bar": 2} == {"foo": 0, "bar": 2}`, false},
 		{`{"foo": 1, "bar": 2} == {"baz": 1}`, false},
 		{`{"foo": 1, "bar": 2} == {}`, false},
+		{"1 <= 1", true},
+		{"1 <= 0", false},
+		{"1 >= 1", true},
+		{"0 >= 1", false},
+		{"1.5 <= 2", true},
+		{"9223372036854775807 + 1 >= 9223372036854775807", true},
+		{`"abc" < "abd"`, true},
+		{`"ab" < "a"`, false},
+		{`"b" > "abc"`, true},
+		{`"a" <= "a"`, true},
+		{`"a" >= "b"`, false},
+		{"[1, 2] < [1, 3]", true},
+		{"[1, 2] > [1, 3]", false},
+		{"[1] < [1, 0]", true},
+		{"[1, 2] <= [1, 2]", true},
+		{"[1, 2] >= [1, 2, 3]", false},
+		{"[2] > [1, 5]", true},
+		{`[["a"], 1] < [["b"], 0]`, true},
+		{"true && false", false},
+		{"true || false", true},
+		{"1 < 2 && 2 < 3", true},
 	}
 
 	for _, tt := range tests {
@@ -214,7 +279,7 @@ func TestIfElseExpression(t *testing.T) {
 }
 
 func testNullObject(t *testing.T, obj object.Object) bool {
-	if obj != NULL {
+	if obj != evaluator.NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
 		return false
 	}
@@ -262,6 +327,10 @@ func TestErrorHandling(t *testing.T) {
 			"5 + true; 5",
 			"type mismatch: INTEGER + BOOLEAN",
 		},
+		{
+			`"a ${undefinedVariable} b"`,
+			"identifier not found: undefinedVariable",
+		},
 		{
 			"-true",
 			"unknown operator: -BOOLEAN",
@@ -305,6 +374,26 @@ if (10 > 1) {
 			`{"name": "Monkey"}[fn(x){x}];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"10 / 0",
+			"integer division by zero",
+		},
+		{
+			"10 % (5 - 5)",
+			"integer modulo by zero",
+		},
+		{
+			"(9223372036854775807 + 1) / 0",
+			"integer division by zero",
+		},
+		{
+			"let f = fn(a, b) { a + b }; f(1);",
+			"wrong number of arguments. want=2, got=1",
+		},
+		{
+			"quote()",
+			"wrong number of arguments. want=1, got=0",
+		},
 	}
 
 	for _, tt := range tests {
@@ -333,6 +422,13 @@ func TestLetStatement(t *testing.T) {
 		{"let a = 5 * 5; a;", 25},
 		{"let a =  5; let b = a; b;", 5},
 		{"let a =  5; let b = a; let c = a + b + 5; c;", 15},
+		// declared again in other blocks, which share the scope
+		{"let a = false; if (a) { let x = 1; x } else { let x = 2; x }", 2},
+		{"fn(a) { if (a) { let x = 1; x } else { let x = 2; x } }(1)", 1},
+		{"let s = 0; let i = 0; while (i < 4) { let s = s + i; i = i + 1 } s", 6},
+		{"fn(n) { let s = 0; let i = 0; while (i < n) { let s = s + i; i = i + 1 } s }(4)", 6},
+		// read before the local is declared
+		{"let x = 1; fn() { let y = x; let x = 2; y }()", 1},
 	}
 
 	for _, tt := range tests {
@@ -340,6 +436,21 @@ func TestLetStatement(t *testing.T) {
 	}
 }
 
+func TestNullVariables(t *testing.T) {
+	// variables holding null are bound like any other
+	tests := []string{
+		"let f = fn(a) { a }; f(if (false) { 1 })",
+		"fn() { let x = if (false) { 1 }; x }()",
+		"fn() { let xs = []; for x in [1, [][0], 3] { xs = push(xs, x); } xs[1] }()",
+		`fn() { let x = 1; x = puts("a"); x }()`,
+		`fn() { let x = puts("a"); x = 2; x = [][0]; x }()`,
+	}
+
+	for _, input := range tests {
+		testNullObject(t, testEval(t, input))
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := `fn(x) { x + 2; };`
 	expectedBody := "(x + 2)"
@@ -390,6 +501,19 @@ addTwo(5);`
 	testIntegerObject(t, testEval(t, input), 7)
 }
 
+func TestBuiltinNames(t *testing.T) {
+	for _, name := range []string{"len", "starts_with", "keys", "map", "sum"} {
+		builtin, ok := evaluator.LookupBuiltin(name)
+		if !ok {
+			t.Errorf("builtin %s not found", name)
+			continue
+		}
+		if builtin.Name != name {
+			t.Errorf("builtin %s is named %q", name, builtin.Name)
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -529,6 +653,14 @@ func TestArrayIndexExpression(t *testing.T) {
 		},
 		{
 			`[1, 2, 3][-1]`,
+			3,
+		},
+		{
+			`[1, 2, 3][-3]`,
+			1,
+		},
+		{
+			`[1, 2, 3][-4]`,
 			nil,
 		},
 	}
@@ -559,8 +691,8 @@ func TestHashLiterals(t *testing.T) {
 		(&object.String{Value: "two"}).HashKey():   2,
 		(&object.String{Value: "three"}).HashKey(): 3,
 		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+		evaluator.TRUE.HashKey():                   5,
+		evaluator.FALSE.HashKey():                  6,
 	}
 
 	evaluated := testEval(t, input)
@@ -643,9 +775,1062 @@ func TestWhileStatement(t *testing.T) {
 			`,
 			10,
 		},
+		{
+			`let i = 0;
+			while (true) {
+				i += 1;
+				if (i == 5) { break; }
+			}
+			i;
+			`,
+			5,
+		},
+		{
+			`let i = 0;
+			let sum = 0;
+			while (i < 10) {
+				i += 1;
+				if (i % 2 == 0) { continue; }
+				sum += i;
+			}
+			sum;
+			`,
+			25,
+		},
+		{
+			`let i = 0;
+			let n = 0;
+			while (i < 3) {
+				i += 1;
+				let j = 0;
+				while (true) {
+					j += 1;
+					if (j > i) { break; }
+					n += 1;
+				}
+			}
+			n;
+			`,
+			6,
+		},
+		{
+			`let f = fn() {
+				while (true) { return 3; }
+			};
+			f();
+			`,
+			3,
+		},
+		{
+			`let i = 0;
+			while (true) {
+				try { break; } finally { i = 7; }
+			}
+			i;
+			`,
+			7,
+		},
 	}
 
 	for _, tt := range tests {
 		testIntegerObject(t, testEval(t, tt.input), tt.expected)
 	}
 }
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"5 + true;", "1:1"},
+		{"let a = 1;\nlet b = a + foobar;", "2:13"},
+		{"let f = fn(x) {\n\tx - \"a\"\n};\nf(1);", "2:2"},
+		{"len(1)", "1:1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T (%+v)",
+				evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position. want=%s, got=%s",
+				tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedKind object.ErrorKind
+	}{
+		{"5 + true;", object.TYPE_ERROR},
+		{"-true", object.TYPE_ERROR},
+		{"foobar", object.NAME_ERROR},
+		{"len(1, 2)", object.ARGUMENT_ERROR},
+		{"1(2)", object.TYPE_ERROR},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T (%+v)",
+				evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind. want=%s, got=%s",
+				tt.expectedKind, errObj.Kind)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// reported before anything runs
+		{`throw "not run"; foo`, "1:18: NameError: identifier not found: foo"},
+		{`throw "not run"; let f = fn() { x = 1 };`,
+			"1:33: NameError: assignment to undeclared variable: x"},
+		{`throw "not run"; let f = fn() { let a = 1; if (a) { let b = 2; let b = 3; } };`,
+			"1:68: NameError: duplicate declaration of b"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Inspect() != "ERROR: "+tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", "ERROR: "+tt.expected, errObj.Inspect())
+		}
+	}
+}
+
+func TestLocalsBeforeDeclaration(t *testing.T) {
+	// functions see the locals declared after them, once they are bound
+	input := `fn() {
+	let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+	let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+	even(4)
+}()`
+	testBooleanObject(t, testEval(t, input), true)
+
+	// but not before
+	input = "let x = 1; fn() { let f = fn() { x }; let r = f(); let x = 2; r }()"
+	evaluated := testEval(t, input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "identifier not found: x" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `let inner = fn(x) {
+	x + "a"
+};
+let outer = fn(x) {
+	inner(x * 2)
+};
+fn(y) { outer(y) }(1);`
+
+	expected := []object.Frame{
+		{Function: "inner", Pos: token.Position{Line: 5, Column: 2}},
+		{Function: "outer", Pos: token.Position{Line: 7, Column: 9}},
+		{Function: "", Pos: token.Position{Line: 7, Column: 1}},
+	}
+
+	evaluated := testEval(t, input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(errObj.Trace) != len(expected) {
+		t.Fatalf("wrong number of frames. want=%d, got=%d (%+v)",
+			len(expected), len(errObj.Trace), errObj.Trace)
+	}
+
+	for i, frame := range expected {
+		if errObj.Trace[i] != frame {
+			t.Errorf("trace[%d] wrong. want=%+v, got=%+v",
+				i, frame, errObj.Trace[i])
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } };
+		sum(300000, 0)`, 45000150000},
+		{`let count = fn(n) { if (n == 0) { return 0; } return count(n - 1); };
+		count(300000)`, 0},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		even(300001)`, false},
+		{`let f = fn(n) { while (true) { if (n == 0) { return "done"; } return f(n - 1); } };
+		f(300000)`, "done"},
+		{`let f = fn(n) { if (n == 0) { throw "bottom" } f(n - 1) };
+		try { f(300000) } catch (e) { e["message"] }`, "bottom"},
+		{`let f = fn(n, acc) { if (n == 0) { acc } else { f(n - 1, acc + 1) } };
+		f(1000000, 0)`, 1000000},
+		{`let f = fn(...xs) { if (len(xs) == 0) { "done" } else { f(...rest(xs)) } };
+		let xs = [];
+		for x in range(1000) { xs = push(xs, x); }
+		f(...xs)`, "done"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		}
+	}
+
+	// of the frames of the functions left by tail calls, only those of
+	// the first and the last ones are kept
+	evaluated := testEval(t, `let f = fn(n) { if (n == 0) { throw "bottom" } f(n - 1) };
+f(300000)`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+	expected := strings.Repeat("\tat f (1:48)\n", 9) + "\t... 299991 tail calls\n\tat f (2:1)\n"
+	if errObj.StackTrace() != expected {
+		t.Errorf("wrong stack trace. want=%q, got=%q", expected, errObj.StackTrace())
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { 1 + true; 1 } catch (e) { 2 }`, 2},
+		{`try { throw "oops" } catch (e) { e["message"] }`, "oops"},
+		{`try { throw "oops" } catch (e) { e["kind"] }`, "Error"},
+		{`let kind = try { foo } catch (e) { e["kind"] }; let foo = 1; kind`, "NameError"},
+		{`try { 1 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try {
+			1;
+			throw "oops"
+		} catch (e) { e["line"] }`, 3},
+		{`try { throw {"kind": "ValueError", "message": "bad"} } catch (e) { e["kind"] + ": " + e["message"] }`, "ValueError: bad"},
+		{`try { throw 42 } catch (e) { e["message"] }`, "42"},
+		{`try { try { throw "a" } catch (e) { throw e } } catch (e) { e["message"] }`, "a"},
+		{`let f = fn() { try { return 1; } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { throw "a" } finally { return 2; } }; f()`, 2},
+		{`try { 1 } finally { let x = 5; }; x`, 5},
+		{`let e = 1; try { throw "a" } catch (e) { 2 }; e`, 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestUncaughtError(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedKind    object.ErrorKind
+		expectedMessage string
+	}{
+		{`throw "oops"`, object.USER_ERROR, "oops"},
+		{`try { throw "oops" } finally { 1 }`, object.USER_ERROR, "oops"},
+		{`try { 1 } catch (e) { 2 } finally { throw "in finally" }`, object.USER_ERROR, "in finally"},
+		{`try { throw "a" } catch (e) { foo }`, object.NAME_ERROR, "identifier not found: foo"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T (%+v)",
+				evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind. want=%s, got=%s",
+				tt.expectedKind, errObj.Kind)
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. want=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"1 / 4.0", 0.25},
+		{"7.5 % 2", 1.5},
+		{"10 - 2.5 * 2", 5},
+		{"1e3 / 8", 125},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. want=%g, got=%g",
+			expected, result.Value)
+		return false
+	}
+
+	return true
+}
+
+func TestFloatComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 == 0.3", false},
+		{"1.5 != 1.5", false},
+		{`{1: "a"}[1.0] == "a"`, true},
+		{`{1.5: "a"}[1.5] == "a"`, true},
+		{"9007199254740993 == 9007199254740992.0", false},
+		{"9007199254740992 == 9007199254740992.0", true},
+		{`{9007199254740992: "a"}[9007199254740992.0] == "a"`, true},
+		{`has_key({9007199254740992.0: "a"}, 9007199254740993)`, false},
+		{"100000000000000000000 == 1e20", true},
+		{`{100000000000000000000: "a"}[1e20] == "a"`, true},
+		{"len(unique([1e20, 100000000000000000000])) == 1", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestNumberConversion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`int(3.99)`, 3},
+		{`int(-3.99)`, -3},
+		{`int(7)`, 7},
+		{`int("42")`, 42},
+		{`float(3)`, 3.0},
+		{`float(" 2.5 ")`, 2.5},
+		{`float(1.5)`, 1.5},
+		{`int("x")`, `could not parse "x" as integer`},
+		{`float("x")`, `could not parse "x" as float`},
+		{`int(0.0 / 0)`, "cannot convert NaN to INTEGER"},
+		{`int(true)`, "argument to `int` not supported, got BOOLEAN"},
+		{`float(1, 2)`, "wrong number of arguments. want=1, got=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)",
+					evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. want=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestBigIntegerArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"(9223372036854775807 + 1) - 1", "9223372036854775807"},
+		{"(9223372036854775807 * 4) / 4", "9223372036854775807"},
+		{"(9223372036854775807 * 10 + 7) % 10", "7"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"99_999_999_999_999_999_999 + 1", "100000000000000000000"},
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"-(9223372036854775807 * 10 + 7) / 10", "-9223372036854775807"},
+		{`let fact = fn(n) { if n < 2 { 1 } else { n * fact(n - 1) } }; fact(25)`,
+			"15511210043330985984000000"},
+		{`int("123456789012345678901234567890")`, "123456789012345678901234567890"},
+		{`int(1e20)`, "100000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Type() != object.INTEGER_OBJ {
+			t.Errorf("object is not INTEGER. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong value. want=%s, got=%s", tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// results that fit into int64 are demoted back
+	testIntegerObject(t, testEval(t, "(9223372036854775807 + 1) - 1"), 9223372036854775807)
+}
+
+func TestBigIntegerComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"9223372036854775807 + 1 > 9223372036854775807", true},
+		{"9223372036854775807 < 9223372036854775807 + 1", true},
+		{"9223372036854775807 * 2 == 9223372036854775807 * 2", true},
+		{"9223372036854775807 * 2 != 9223372036854775807 * 3", true},
+		{"9223372036854775807 * 2 > 1.0", true},
+		{"(9223372036854775807 + 1) * 2 == 18446744073709551616.0", true},
+		{`let k = 9223372036854775807 * 2; {k: true}[9223372036854775807 * 2]`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = fn(a = 1, b = a * 2) { a + b }; f()", 3},
+		{"let f = fn(a = 1, b = a * 2) { a + b }; f(5)", 15},
+		{"let x = 100; let f = fn(a = x) { a }; x = 1; f()", 1},
+		{"let f = fn(first, ...rest) { rest }; f(1, 2, 3)", []int{2, 3}},
+		{"let f = fn(first, ...rest) { rest }; f(1)", []int{}},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1)", []int{1, 2, 0}},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1, 5, 6, 7)", []int{1, 5, 2}},
+		{"let add = fn(a, b, c) { a + b + c }; add(...[1, 2, 3])", 6},
+		{"let add = fn(a, b, c) { a + b + c }; let xs = [2, 3]; add(1, ...xs)", 6},
+		{"let f = fn(...xs) { xs }; f(...[], 1, ...[2, 3])", []int{1, 2, 3}},
+		{"[0, ...[1, 2], 3]", []int{0, 1, 2, 3}},
+		{"len(...[[1, 2]])", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int:
+			testArrayObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestArityErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"fn(a, b) { a }(1, 2, 3)", "wrong number of arguments. want=2, got=3"},
+		{"fn() { 1 }(1)", "wrong number of arguments. want=0, got=1"},
+		{"fn(a, b = 1) { a }()", "wrong number of arguments. want=1 to 2, got=0"},
+		{"fn(a, b = 1) { a }(1, 2, 3)", "wrong number of arguments. want=1 to 2, got=3"},
+		{"fn(a, ...rest) { a }()", "wrong number of arguments. want=at least 1, got=0"},
+		{"fn(a = foo) { a }()", "identifier not found: foo"},
+		{"fn(a) { a }(...1)", "cannot spread INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T (%+v)",
+				evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. want=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = 1; a = 2; a", 2},
+		{"let a = 1; a = 2", 2},
+		{"let a = 1; let b = 1; a = b = 5; a + b", 10},
+		{"let a = 10; a += 5; a", 15},
+		{"let a = 10; a -= 5; a", 5},
+		{"let a = 10; a *= 5; a", 50},
+		{"let a = 10; a /= 5; a", 2},
+		{"let a = 10; a %= 4; a", 2},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{`
+		let count = 0;
+		let incr = fn() { count += 1 };
+		incr(); incr();
+		count`, 2},
+		{`
+		let sum = fn(n) {
+			let total = 0;
+			let i = 0;
+			while (i < n) {
+				i += 1;
+				total += i;
+			}
+			total
+		};
+		sum(10)`, 55},
+		{`let a = 1; let f = fn() { let a = 10; a = 20; a }; f() + a`, 21},
+		{"let arr = [1, 2, 3]; arr[1] = 20; arr", []int{1, 20, 3}},
+		{"let arr = [1, 2, 3]; arr[2] *= 10; arr[2]", 30},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] + h["b"]`, 3},
+		{`let h = {"a": 1}; h["a"] += 10; h["a"]`, 11},
+		{"let arr = [[1], [2]]; arr[1][0] = 5; arr[1]", []int{5}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case []int:
+			testArrayObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"x = 1", "assignment to undeclared variable: x"},
+		{"x += 1", "assignment to undeclared variable: x"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{`let a = [1]; a["0"] = 2`, "array index must be INTEGER, got STRING"},
+		{`let h = {}; h["k"] += 1`, "key not found: k"},
+		{`let h = {}; h[[1]] = 1`, "unusable as hash key: ARRAY"},
+		{`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T (%+v)",
+				evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. want=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let s = 0; for x in [1, 2, 3] { s += x; } s", 6},
+		{"let s = 0; for i, x in [10, 20, 30] { s += i * x; } s", 80},
+		{`let s = ""; for c in "日本語" { s = c + s; } s`, "語本日"},
+		{`let s = 0; for i, c in "abc" { s += i; } s`, 3},
+		{`let s = 0; for k, v in {"a": 1, "b": 2} { s += v; } s`, 3},
+		{`let s = ""; for k in {"a": 1} { s = k; } s`, "a"},
+		{"let s = 0; for i in range(5) { s += i; } s", 10},
+		{"let s = 0; for i in range(2, 5) { s += i; } s", 9},
+		{"let s = []; for i in range(10, 0, -4) { s = push(s, i); } s", []int{10, 6, 2}},
+		{"let s = 0; for i in range(5, 0) { s += 1; } s", 0},
+		{"let s = 0; for i in range(100) { if (i == 3) { break; } s += i; } s", 3},
+		{"let s = 0; for i in range(5) { if (i % 2 == 0) { continue; } s += i; } s", 4},
+		{"let f = fn() { for x in [1, 2, 3] { if (x == 2) { return x; } } }; f()", 2},
+		{"for x in [1, 2] { let y = x; } y", "identifier not found: y"},
+		{"for x in 5 { }", "cannot iterate over INTEGER"},
+		{"range(1, 2, 0)", "range step must not be zero"},
+		{`range("a")`, "arguments to `range` must be INTEGER, got STRING"},
+		{"range()", "wrong number of arguments. want=1 to 3, got=0"},
+		{"len(range(0, 10, 3))", 4},
+		{"len(range(-9223372036854775807 - 1, 9223372036854775807))", "18446744073709551615"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int:
+			testArrayObject(t, evaluated, expected)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value. want=%q, got=%q", expected, obj.Value)
+				}
+			case *object.BigInteger:
+				if obj.Value.String() != expected {
+					t.Errorf("BigInteger has wrong value. want=%s, got=%s", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. want=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected object %T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestLogicalExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let x = if (false) { 1 }; let d = "default"; x || d`, "default"},
+		{`let x = "set"; x || "default"`, "set"},
+		{"1 && 2", 2},
+		{"false && 2", false},
+		{"false || 0", 0},
+		{"let n = 0; let f = fn() { n += 1; true }; false && f(); true || f(); n", 0},
+		{"let n = 0; let f = fn() { n += 1; true }; true && f(); false || f(); n", 2},
+		{"false && 1 / 0", false},
+		{"true && 1 / 0", "integer division by zero"},
+		{"[1] < [\"a\"]", "type mismatch: INTEGER < STRING"},
+		{"{} < {}", "unknown operator: HASH < HASH"},
+		{"[1] + [2]", "unknown operator: ARRAY + ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message. want=%q, got=%q", expected, err.Message)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"6 & 3", 6 & 3},
+		{"6 | 3", 6 | 3},
+		{"6 ^ 3", 6 ^ 3},
+		{"~5", ^5},
+		{"~-1", 0},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 >> 100", 0},
+		{"-1 >> 100", -1},
+		{"0xF0 & 0x3C >> 2", 0xF0 & (0x3C >> 2)},
+		{"let flags = 0b1010; (flags >> 1) & 1", 1},
+		{"1 << 63", "9223372036854775808"},
+		{"1 << 64", "18446744073709551616"},
+		{"(1 << 64) >> 60", 16},
+		{"(1 << 64) | 1", "18446744073709551617"},
+		{"(1 << 64) & 0xFF", 0},
+		{"~(1 << 64)", "-18446744073709551617"},
+		{"-1 << 63", -9223372036854775808},
+		{"1 << -1", "negative shift count: -1"},
+		{"1 >> -1", "negative shift count: -1"},
+		{"1 << (1 << 64)", "shift count too large: 18446744073709551616"},
+		{"1 << 4294967295", "shift count too large: 4294967295"},
+		{"1 << 1048577", "shift count too large: 1048577"},
+		{"1.0 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"~1.0", "unknown operator: ~FLOAT"},
+		{`~"a"`, "unknown operator: ~STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.BigInteger:
+				if obj.Value.String() != expected {
+					t.Errorf("BigInteger has wrong value. want=%s, got=%s", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. want=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected object %T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Inspect() of the result
+	}{
+		{`len("日本語")`, "3"},
+		{`"日本語"[1]`, "本"},
+		{`"abc"[3]`, "null"},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[-4]`, "null"},
+		{`slice("日本語です", 1, 3)`, "本語"},
+		{`slice("hello", 2)`, "llo"},
+		{`slice("hello", -5, 100)`, "hello"},
+		{`slice("hello", -3, -1)`, "ll"},
+		{`slice("hello", 4, 2)`, ""},
+		{`slice([1, 2, 3, 4], 1, 3)`, "[2, 3]"},
+		{`split("a,b,,c", ",")`, "[a, b, , c]"},
+		{`split("  a  b\tc\n")`, "[a, b, c]"},
+		{`split("日本", "")`, "[日, 本]"},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`join(["a", 1, true])`, "a1true"},
+		{`join([], ",")`, ""},
+		{`trim("  hi \n")`, "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`upper("Hello, 世界")`, "HELLO, 世界"},
+		{`lower("HeLLo")`, "hello"},
+		{`replace("aaa", "a", "b")`, "bbb"},
+		{`replace("aaa", "a", "b", 2)`, "bba"},
+		{`contains("seafood", "foo")`, "true"},
+		{`contains("seafood", "bar")`, "false"},
+		{`starts_with("golang", "go")`, "true"},
+		{`ends_with("golang", "go")`, "false"},
+		{`index_of("日本語", "語")`, "2"},
+		{`index_of("abc", "z")`, "-1"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`chars("日本")`, "[日, 本]"},
+		{`chars("")`, "[]"},
+		{`format("%s is %d years, %.2f%%", "Bob", 42, 99.5)`, "Bob is 42 years, 99.50%"},
+		{`format("%v %v %v", [1, 2], true, 9223372036854775807 + 1)`, "[1, 2] true 9223372036854775808"},
+		{`format("%05d|%-4s|%x", 42, "ab", 255)`, "00042|ab  |ff"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`upper(1)`, "argument to `upper` must be STRING, got INTEGER"},
+		{`upper("a", "b")`, "wrong number of arguments. want=1, got=2"},
+		{`split("a", 1)`, "second argument to `split` must be STRING, got INTEGER"},
+		{`split()`, "wrong number of arguments. want=1 to 2, got=0"},
+		{`join("a")`, "argument to `join` must be ARRAY, got STRING"},
+		{`replace("a", "b", 1)`, "third argument to `replace` must be STRING, got INTEGER"},
+		{`replace("a", "b", "c", "d")`, "fourth argument to `replace` must be INTEGER, got STRING"},
+		{`contains(1, "a")`, "first argument to `contains` must be STRING, got INTEGER"},
+		{`repeat("a", -1)`, "negative repeat count: -1"},
+		{`repeat("a", 9223372036854775807)`, "repeat count too large: 9223372036854775807"},
+		{`slice(1, 2)`, "slice operator not supported: INTEGER"},
+		{`slice("a", "b")`, "slice index must be INTEGER, got STRING"},
+		{`format()`, "wrong number of arguments. want=at least 1, got=0"},
+		{`format(1)`, "argument to `format` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestArrayBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Inspect() of the result
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6]"},
+		{"map([], fn(x) { x })", "[]"},
+		{"map([-1, 2], len)", "ERROR: 1:1: TypeError: argument to `len` not supported, got INTEGER"},
+		{"filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })", "[2, 4]"},
+		{"reduce([1, 2, 3], 10, fn(acc, x) { acc + x })", "16"},
+		{`reduce([], "a", fn(acc, x) { acc + x })`, "a"},
+		{"sort([3, 1, 2])", "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{"sort([3, 1, 2], fn(a, b) { a > b })", "[3, 2, 1]"},
+		{"sort([[2, 1], [1, 2], [2, 0]], fn(a, b) { a[0] - b[0] })", "[[1, 2], [2, 1], [2, 0]]"},
+		{"let a = [2, 1]; sort(a); a", "[2, 1]"},
+		{`sort([1, "a"])`, "ERROR: 1:1: TypeError: type mismatch: STRING < INTEGER"},
+		{"reverse([1, 2, 3])", "[3, 2, 1]"},
+		{"concat([1], [], [2, 3])", "[1, 2, 3]"},
+		{"concat()", "[]"},
+		{"contains([1, [2], 3], [2])", "true"},
+		{`contains([1, 2], "1")`, "false"},
+		{`contains("hello", "ell")`, "true"},
+		{`index_of([1, 2, 3], 3)`, "2"},
+		{`index_of([1, 2, 3], 4)`, "-1"},
+		{`index_of("日本語", "語")`, "2"},
+		{"zip([1, 2, 3], [4, 5])", "[[1, 4], [2, 5]]"},
+		{"flatten([1, [2, [3, [4]]]])", "[1, 2, 3, 4]"},
+		{"flatten([1, [2, [3, [4]]]], 1)", "[1, 2, [3, [4]]]"},
+		{"unique([1, 2, 1, [3], [3], 2])", "[1, 2, [3]]"},
+		{"any([false, if (false) { 1 }, 1])", "true"},
+		{"any([1, 2], fn(x) { x > 2 })", "false"},
+		{"all([])", "true"},
+		{"all([1, 2], fn(x) { x > 0 })", "true"},
+		{"all([1, if (false) { 1 }])", "false"},
+		{"min([3, 1, 2])", "1"},
+		{"max(3, 1.5, 7)", "7"},
+		{`max(["a", "c", "b"])`, "c"},
+		{"sum([1, 2, 3])", "6"},
+		{"sum([1.5, 2])", "3.5"},
+		{`sum(["a", "b"], "")`, "ab"},
+		{"sum([])", "0"},
+		{`len(map(split(repeat("a,", 99999) + "a", ","), fn(x) { x }))`, "100000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestArrayBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`map("a", fn(x) { x })`, "first argument to `map` must be ARRAY, got STRING"},
+		{`map([1])`, "wrong number of arguments. want=2, got=1"},
+		{`map([1], 1)`, "not a function: INTEGER"},
+		{`filter([1], fn(x) { x + "a" })`, "type mismatch: INTEGER + STRING"},
+		{`reverse(1)`, "argument to `reverse` must be ARRAY, got INTEGER"},
+		{`concat([1], 2)`, "second argument to `concat` must be ARRAY, got INTEGER"},
+		{`concat([1], [2], [3], [4], 5)`, "argument 5 to `concat` must be ARRAY, got INTEGER"},
+		{`zip([1], [2], [3], [4], [5], 6)`, "argument 6 to `zip` must be ARRAY, got INTEGER"},
+		{`zip()`, "wrong number of arguments. want=at least 1, got=0"},
+		{`flatten([1], -1)`, "negative flatten depth: -1"},
+		{`min([])`, "`min` of empty array"},
+		{`max()`, "wrong number of arguments. want=at least 1, got=0"},
+		{`sum([1, "a"])`, "type mismatch: INTEGER + STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Inspect() of the result
+	}{
+		{`{"b": 1, "a": 2, 3: 3}`, "{b: 1, a: 2, 3: 3}"},
+		{`let h = {"b": 1}; h["a"] = 2; h["b"] = 3; h`, "{b: 3, a: 2}"},
+		{`keys({"b": 1, "a": 2, true: 3})`, "[b, a, true]"},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`entries({"b": 1, "a": 2})`, "[[b, 1], [a, 2]]"},
+		{`keys({})`, "[]"},
+		{`has_key({"a": 1}, "a")`, "true"},
+		{`has_key({1: 1}, 1.0)`, "true"},
+		{`has_key({"a": 1}, "b")`, "false"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b")`, "2"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b"); h`, "{a: 1, c: 3}"},
+		{`let h = {"a": 1}; delete(h, "x"); h`, "{a: 1}"},
+		{`let h = {"a": 1}; delete(h, "a"); h["a"] = 2; h["b"] = 3; h`, "{a: 2, b: 3}"},
+		{`merge({"a": 1, "b": 2}, {"c": 3, "a": 4})`, "{a: 4, b: 2, c: 3}"},
+		{`let h = {"a": 1}; merge(h, {"b": 2}); h`, "{a: 1}"},
+		{`merge()`, "{}"},
+		{`let s = ""; for k, v in {"z": 1, "y": 2, "x": 3} { s = s + k } s`, "zyx"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHashBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`keys([1])`, "argument to `keys` must be HASH, got ARRAY"},
+		{`values()`, "wrong number of arguments. want=1, got=0"},
+		{`has_key({}, [1])`, "unusable as hash key: ARRAY"},
+		{`delete(1, "a")`, "first argument to `delete` must be HASH, got INTEGER"},
+		{`merge({}, 1)`, "second argument to `merge` must be HASH, got INTEGER"},
+		{`merge({}, {}, {}, {}, 5)`, "argument 5 to `merge` must be HASH, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Inspect() of the result
+	}{
+		{"[1, 2, 3, 4, 5][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4, 5][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4, 5][3:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:]", "[1, 2, 3, 4, 5]"},
+		{"[1, 2, 3, 4, 5][-2:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:-2]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4, 5][::2]", "[1, 3, 5]"},
+		{"[1, 2, 3, 4, 5][::-1]", "[5, 4, 3, 2, 1]"},
+		{"[1, 2, 3, 4, 5][3:0:-1]", "[4, 3, 2]"},
+		{"[1, 2, 3, 4, 5][-1:-4:-2]", "[5, 3]"},
+		{"[1, 2, 3, 4, 5][-100:100]", "[1, 2, 3, 4, 5]"},
+		{"[1, 2, 3, 4, 5][4:1]", "[]"},
+		{"[1, 2, 3, 4, 5][::9223372036854775807]", "[1]"},
+		{"[1, 2, 3, 4, 5][::-9223372036854775807 - 1]", "[5]"},
+		{"[1, 2, 3][0:9223372036854775807 + 1]", "[1, 2, 3]"},
+		{"[][::-1]", "[]"},
+		{`"日本語です"[1:3]`, "本語"},
+		{`"hello"[::-1]`, "olleh"},
+		{`"hello"[-3:]`, "llo"},
+		{`"hello"[3:1]`, ""},
+		{`"hello"[::2]`, "hlo"},
+		{"let a = [1, 2, 3]; let b = a[:]; b[0] = 9; a", "[1, 2, 3]"},
+		{"let a = [1, 2, 3]; a[-1] = 9; a", "[1, 2, 9]"},
+		{"let a = [1, 2, 3]; a[-3] += 10; a", "[11, 2, 3]"},
+		{"[1, 2][::0]", "ERROR: 1:1: ValueError: slice step cannot be zero"},
+		{`[1, 2]["a":]`, "ERROR: 1:1: TypeError: slice index must be INTEGER, got STRING"},
+		{"1[1:]", "ERROR: 1:1: TypeError: slice operator not supported: INTEGER"},
+		{"let a = [1]; a[-2] = 0", "ERROR: 1:14: IndexError: index out of range: -2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
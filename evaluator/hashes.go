@@ -0,0 +1,114 @@
+package evaluator
+
+import (
+	"github.com/tatsuya4559/monkey/object"
+)
+
+// Hash builtins list pairs in insertion order.
+
+// hashArg returns the i-th argument of the builtin name as a hash.
+func hashArg(name string, args []object.Object, i int) (*object.Hash, *object.Error) {
+	hash, ok := args[i].(*object.Hash)
+	if !ok {
+		return nil, newError(object.TYPE_ERROR, "%s to `%s` must be HASH, got %s",
+			argumentName(args, i), name, args[i].Type())
+	}
+	return hash, nil
+}
+
+func hashKeyOf(obj object.Object) (object.HashKey, *object.Error) {
+	key, ok := obj.(object.Hashable)
+	if !ok {
+		return object.HashKey{}, newError(object.TYPE_ERROR, "unusable as hash key: %s", obj.Type())
+	}
+	return key.HashKey(), nil
+}
+
+// hashElements returns a builtin that lists an element built by fn for
+// each pair of a hash.
+func hashElements(name string, fn func(pair object.HashPair) object.Object) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if err := checkArgCount(args, 1, 1); err != nil {
+			return err
+		}
+		hash, err := hashArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+
+		pairs := hash.OrderedPairs()
+		elements := make([]object.Object, len(pairs))
+		for i, pair := range pairs {
+			elements[i] = fn(pair)
+		}
+		return &object.Array{Elements: elements}
+	}
+}
+
+var (
+	_keys = hashElements("keys", func(pair object.HashPair) object.Object {
+		return pair.Key
+	})
+	_values = hashElements("values", func(pair object.HashPair) object.Object {
+		return pair.Value
+	})
+	_entries = hashElements("entries", func(pair object.HashPair) object.Object {
+		return &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+	})
+)
+
+func _hasKey(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+	hash, err := hashArg("has_key", args, 0)
+	if err != nil {
+		return err
+	}
+	key, err := hashKeyOf(args[1])
+	if err != nil {
+		return err
+	}
+
+	_, ok := hash.Pairs[key]
+	return nativeBoolToBooleanObject(ok)
+}
+
+// delete removes a key from a hash in place and returns its value, or
+// null if the key was not present.
+func _delete(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+	hash, err := hashArg("delete", args, 0)
+	if err != nil {
+		return err
+	}
+	key, err := hashKeyOf(args[1])
+	if err != nil {
+		return err
+	}
+
+	pair, ok := hash.Pairs[key]
+	if !ok {
+		return NULL
+	}
+	hash.Delete(key)
+	return pair.Value
+}
+
+// merge returns a new hash with the pairs of all arguments. Later
+// arguments win for duplicate keys, which keep their first position.
+func _merge(args ...object.Object) object.Object {
+	merged := object.NewHash()
+	for i := range args {
+		hash, err := hashArg("merge", args, i)
+		if err != nil {
+			return err
+		}
+		for _, key := range hash.Keys {
+			merged.Set(key, hash.Pairs[key])
+		}
+	}
+	return merged
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/tatsuya4559/monkey/object"
+)
+
+// addInt64 returns a+b and whether it did not overflow.
+func addInt64(a, b int64) (int64, bool) {
+	c := a + b
+	if (a > 0 && b > 0 && c < 0) || (a < 0 && b < 0 && c >= 0) {
+		return 0, false
+	}
+	return c, true
+}
+
+// subInt64 returns a-b and whether it did not overflow.
+func subInt64(a, b int64) (int64, bool) {
+	c := a - b
+	if (a >= 0 && b < 0 && c < 0) || (a < 0 && b > 0 && c >= 0) {
+		return 0, false
+	}
+	return c, true
+}
+
+// mulInt64 returns a*b and whether it did not overflow.
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	c := a * b
+	if c/b != a {
+		return 0, false
+	}
+	return c, true
+}
+
+// maxShiftCount bounds the left shifts, which grow the integer by as many
+// bits.
+const maxShiftCount = 1 << 20
+
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInteger:
+		return obj.Value
+	default:
+		return nil
+	}
+}
+
+// evalBigIntegerInfixExpression evaluates integer arithmetic with
+// arbitrary precision. It is used once a result overflows int64.
+func evalBigIntegerInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+
+	if (operator == "/" || operator == "%") && rightVal.Sign() == 0 {
+		return newZeroDivisionError(operator)
+	}
+
+	switch operator {
+	case "+":
+		return object.NewInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return object.NewInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return object.NewInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		// Quo and Rem truncate toward zero like the int64 operators
+		return object.NewInteger(new(big.Int).Quo(leftVal, rightVal))
+	case "%":
+		return object.NewInteger(new(big.Int).Rem(leftVal, rightVal))
+	case "&":
+		return object.NewInteger(new(big.Int).And(leftVal, rightVal))
+	case "|":
+		return object.NewInteger(new(big.Int).Or(leftVal, rightVal))
+	case "^":
+		return object.NewInteger(new(big.Int).Xor(leftVal, rightVal))
+	case "<<", ">>":
+		if rightVal.Sign() < 0 {
+			return newError(object.VALUE_ERROR, "negative shift count: %s", rightVal)
+		}
+		if !rightVal.IsUint64() || rightVal.Uint64() > math.MaxUint32 ||
+			operator == "<<" && rightVal.Uint64() > maxShiftCount {
+			return newError(object.VALUE_ERROR, "shift count too large: %s", rightVal)
+		}
+		n := uint(rightVal.Uint64())
+		if operator == "<<" {
+			return object.NewInteger(new(big.Int).Lsh(leftVal, n))
+		}
+		// Rsh rounds toward negative infinity like the int64 operator
+		return object.NewInteger(new(big.Int).Rsh(leftVal, n))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
@@ -103,6 +103,12 @@ func TestExpandMacros(t *testing.T) {
 			`,
 			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
 		},
+		{
+			`let tail = macro(xs) { quote(unquote(xs)[1:]); };
+			tail([1, 2, 3]);
+			`,
+			`[1, 2, 3][1:]`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,202 @@
+package evaluator
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/lexer"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/parser"
+)
+
+// Prelude, if not nil, is called on the environments of every program and
+// module before it is evaluated.
+var Prelude func(env, macroEnv *object.Environment) error
+
+// LoadPrelude calls Prelude, if set, on the environments of a program.
+func LoadPrelude(env, macroEnv *object.Environment) *object.Error {
+	if Prelude == nil {
+		return nil
+	}
+	if err := Prelude(env, macroEnv); err != nil {
+		return newError(object.IMPORT_ERROR, "cannot load prelude: %s", err)
+	}
+	return nil
+}
+
+// SetMain registers program, the entry file at path, as the main module
+// of the interpreter that env belongs to. The file counts as being
+// imported while it runs, so importing it back is an import cycle.
+func SetMain(path string, program *ast.Program, env, macroEnv *object.Environment) *object.Error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return newError(object.IMPORT_ERROR, "cannot run %q: %s", path, err)
+	}
+
+	modules := env.Modules()
+	modules.Importing = append(modules.Importing, abs)
+	modules.Loaded[abs] = newModule(path, program, env, macroEnv)
+	return nil
+}
+
+// ImportMacros imports the modules of the top-level import statements of
+// program, which runs in env, and defines their macros in macroEnv. It
+// must be called before DefineMacros so that the macros can be expanded
+// in program.
+func ImportMacros(program *ast.Program, env, macroEnv *object.Environment) *object.Error {
+	for _, stmt := range program.Statements {
+		is, ok := stmt.(*ast.ImportStatement)
+		if !ok {
+			continue
+		}
+
+		module, err := importModule(is, env.Modules())
+		if err != nil {
+			if !err.Pos.IsValid() {
+				err.Pos = is.Pos()
+			}
+			return err
+		}
+
+		for _, name := range module.MacroEnv.Names() {
+			if module.IsVisible(name) {
+				macro, _ := module.MacroEnv.Get(name)
+				macroEnv.Set(name, macro)
+			}
+		}
+	}
+	return nil
+}
+
+func evalImportStatement(is *ast.ImportStatement, env *object.Environment) object.Object {
+	module, err := importModule(is, env.Modules())
+	if err != nil {
+		return err
+	}
+
+	env.Set(is.Name(), module)
+	return nil
+}
+
+func evalMemberExpression(me *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := Eval(me.Object, env)
+	if isError(obj) {
+		return obj
+	}
+	return member(obj, me.Member.Value)
+}
+
+func member(obj object.Object, name string) object.Object {
+	module, ok := obj.(*object.Module)
+	if !ok {
+		return newError(object.TYPE_ERROR, "member access not supported: %s", obj.Type())
+	}
+
+	val, ok := module.Get(name)
+	if !ok {
+		return newError(object.NAME_ERROR, "module %s has no member %s",
+			module.Name, name)
+	}
+	return val
+}
+
+// importModule returns the module imported by is, evaluating it if it
+// has not been imported yet by the interpreter of modules. A relative path
+// is resolved from the directory of the importing file.
+func importModule(is *ast.ImportStatement, modules *object.Modules) (*object.Module, *object.Error) {
+	path := is.Path.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(is.Pos().Filename), path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, newError(object.IMPORT_ERROR, "cannot import %q: %s", is.Path.Value, err)
+	}
+
+	// the main file is loaded while it runs, so cycles are checked first
+	importing := modules.Importing
+	for i, p := range importing {
+		if p == abs {
+			cycle := append(importing[i:len(importing):len(importing)], abs)
+			return nil, newError(object.IMPORT_ERROR, "import cycle: %s",
+				strings.Join(cycle, " -> "))
+		}
+	}
+	if module, ok := modules.Loaded[abs]; ok {
+		return module, nil
+	}
+
+	modules.Importing = append(importing, abs)
+	defer func() { modules.Importing = modules.Importing[:len(modules.Importing)-1] }()
+
+	module, errObj := loadModule(path, modules)
+	if errObj != nil {
+		return nil, errObj
+	}
+	modules.Loaded[abs] = module
+	return module, nil
+}
+
+// loadModule evaluates the file at path in new environments of the
+// interpreter of modules.
+func loadModule(path string, modules *object.Modules) (*object.Module, *object.Error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, newError(object.IMPORT_ERROR, "cannot import %q: %s", path, err)
+	}
+
+	l := lexer.NewFile(path, string(src))
+	p := parser.New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		return nil, newError(object.IMPORT_ERROR, "cannot import %q: %s", path, errs)
+	}
+
+	module := newModule(path, program,
+		object.NewModuleEnvironment(modules), object.NewEnvironment())
+
+	if err := LoadPrelude(module.Env, module.MacroEnv); err != nil {
+		return nil, err
+	}
+
+	if err := ImportMacros(program, module.Env, module.MacroEnv); err != nil {
+		return nil, err
+	}
+	DefineMacros(program, module.MacroEnv)
+	expanded := ExpandMacros(program, module.MacroEnv)
+
+	if err, ok := Engine(expanded, module.Env).(*object.Error); ok {
+		return nil, err
+	}
+	return module, nil
+}
+
+// newModule returns the module of program, the file at path, which runs
+// in env.
+func newModule(path string, program *ast.Program, env, macroEnv *object.Environment) *object.Module {
+	name := filepath.Base(path)
+	return &object.Module{
+		Name:     strings.TrimSuffix(name, filepath.Ext(name)),
+		Path:     path,
+		Env:      env,
+		MacroEnv: macroEnv,
+		Exports:  exportedNames(program),
+	}
+}
+
+// exportedNames returns the names of the exported let statements of
+// program, or nil if there are none.
+func exportedNames(program *ast.Program) map[string]bool {
+	var exports map[string]bool
+	for _, stmt := range program.Statements {
+		if ls, ok := stmt.(*ast.LetStatement); ok && ls.Exported {
+			if exports == nil {
+				exports = make(map[string]bool)
+			}
+			exports[ls.Name.Value] = true
+		}
+	}
+	return exports
+}
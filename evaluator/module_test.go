@@ -0,0 +1,135 @@
+package evaluator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tatsuya4559/monkey/lexer"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/parser"
+)
+
+// writeFiles writes files into a new temporary directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testEvalFile(t *testing.T, filename, input string) object.Object {
+	t.Helper()
+	l := lexer.NewFile(filename, input)
+	p := parser.New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+
+	if err := SetMain(filename, program, env, macroEnv); err != nil {
+		return err
+	}
+	if err := ImportMacros(program, env, macroEnv); err != nil {
+		return err
+	}
+	DefineMacros(program, macroEnv)
+	expanded := ExpandMacros(program, macroEnv)
+	return Eval(expanded, env)
+}
+
+func TestImport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib/math.mnk": `
+			import "counter.mnk";
+			export let square = fn(x) { counter.inc(); x * x };
+			export let twice = macro(x) { quote(unquote(x) + unquote(x)) };
+			let hidden = 1;
+			let helper = macro(x) { x };
+		`,
+		"lib/counter.mnk": `
+			let count = 0;
+			let inc = fn() { count += 1 };
+		`,
+	})
+
+	tests := []struct {
+		input    string
+		expected string // Inspect() of the result
+	}{
+		{`import "lib/math.mnk"; math.square(3)`, "9"},
+		{`import "lib/math.mnk" as m; m.square(2) + twice(5)`, "14"},
+		{`import "lib/math.mnk"; math`, "<module math>"},
+		// the module is shared with math, but not with other programs
+		{`import "lib/math.mnk"; math.square(3); import "lib/counter.mnk" as c; c.inc(); c.count`, "2"},
+		{`import "lib/counter.mnk" as c; c.inc(); c.count`, "1"},
+		{`import "lib/math.mnk"; math.hidden`,
+			"ERROR: main.mnk:1:24: NameError: module math has no member hidden"},
+		{`import "lib/math.mnk"; math.nothing`,
+			"ERROR: main.mnk:1:24: NameError: module math has no member nothing"},
+		{`import "lib/math.mnk"; helper(1)`,
+			"ERROR: main.mnk:1:24: NameError: identifier not found: helper"},
+		{`1.x`, "ERROR: main.mnk:1:1: TypeError: member access not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalFile(t, filepath.Join(dir, "main.mnk"), tt.input)
+		got := strings.Replace(evaluated.Inspect(), dir+string(filepath.Separator), "", -1)
+		if got != tt.expected {
+			t.Errorf("%s: want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.mnk":      `import "b.mnk";`,
+		"b.mnk":      `import "a.mnk";`,
+		"broken.mnk": `let = 1;`,
+		"throws.mnk": `let x = 1; throw "boom";`,
+	})
+
+	tests := []struct {
+		filename        string
+		input           string
+		expectedKind    object.ErrorKind
+		expectedMessage string
+	}{
+		{"main.mnk", `import "a.mnk";`, object.IMPORT_ERROR, "import cycle: a.mnk -> b.mnk -> a.mnk"},
+		// the main file is not run again as a module
+		{"a.mnk", `import "b.mnk";`, object.IMPORT_ERROR, "import cycle: a.mnk -> b.mnk -> a.mnk"},
+		{"main.mnk", `import "missing.mnk";`, object.IMPORT_ERROR, `cannot import "missing.mnk"`},
+		{"main.mnk", `import "broken.mnk";`, object.IMPORT_ERROR, `cannot import "broken.mnk"`},
+		{"main.mnk", `import "throws.mnk";`, object.USER_ERROR, "boom"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalFile(t, filepath.Join(dir, tt.filename), tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("%s: wrong error kind. want=%s, got=%s", tt.input, tt.expectedKind, errObj.Kind)
+		}
+		message := strings.Replace(errObj.Message, dir+string(filepath.Separator), "", -1)
+		if !strings.HasPrefix(message, tt.expectedMessage) {
+			t.Errorf("%s: wrong error message. want prefix %q, got=%q",
+				tt.input, tt.expectedMessage, message)
+		}
+	}
+}
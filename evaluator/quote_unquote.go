@@ -8,11 +8,18 @@ import (
 )
 
 func quote(node ast.Node, env *object.Environment) object.Object {
-	node = evalUnquoteCalls(node, env)
+	node = replaceUnquoteCalls(node, func(arg ast.Expression) object.Object {
+		return Eval(arg, env)
+	})
 	return &object.Quote{Node: node}
 }
 
-func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
+// replaceUnquoteCalls replaces each unquote call in quoted by the value of
+// its argument. The calls are visited in the order of ast.Modify.
+func replaceUnquoteCalls(
+	quoted ast.Node,
+	unquote func(arg ast.Expression) object.Object,
+) ast.Node {
 	return ast.Modify(quoted, func(node ast.Node) ast.Node {
 		if !isUnquoteCall(node) {
 			return node
@@ -26,8 +33,7 @@ func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
 			return node
 		}
 
-		unquoted := Eval(call.Arguments[0], env)
-		return convertObjectToASTNode(unquoted)
+		return convertObjectToASTNode(unquote(call.Arguments[0]))
 	})
 }
 
@@ -48,6 +54,14 @@ func convertObjectToASTNode(obj object.Object) ast.Node {
 		}
 		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
 
+	case *object.BigInteger:
+		t := token.Token{Type: token.INT, Literal: obj.Value.String()}
+		return &ast.IntegerLiteral{Token: t, Big: obj.Value}
+
+	case *object.Float:
+		t := token.Token{Type: token.FLOAT, Literal: obj.Inspect()}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}
+
 	case *object.Boolean:
 		var t token.Token
 		if obj.Value {
@@ -1,4 +1,4 @@
-package evaluator
+package evaluator_test
 
 import (
 	"testing"
@@ -104,6 +104,10 @@ func TestQuoteUnquote(t *testing.T) {
 			`quote(unquote("foo" + "bar"));`,
 			`foobar`,
 		},
+		{
+			`quote(a[unquote(1 + 1):unquote(-1)]);`,
+			`(a[2:-1])`,
+		},
 	}
 
 	for _, tt := range tests {
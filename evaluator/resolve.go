@@ -0,0 +1,58 @@
+package evaluator
+
+import (
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/resolver"
+)
+
+// resolve resolves the variables of program, which runs in env, and
+// returns the first error found.
+func resolve(program *ast.Program, env *object.Environment) *object.Error {
+	// the bindings do not depend on env, so a program is resolved once
+	if program.Resolved {
+		return nil
+	}
+	errs := resolver.Resolve(program, func(name string) bool {
+		if _, ok := env.Get(name); ok {
+			return true
+		}
+		_, ok := builtins[name]
+		return ok
+	})
+	if len(errs) == 0 {
+		program.Resolved = true
+		return nil
+	}
+
+	err := newError(object.NAME_ERROR, "%s", errs[0].Msg)
+	err.Pos = errs[0].Pos
+	return err
+}
+
+// lookup returns the value of the variable ident refers to. It reports
+// false if the variable is not bound.
+func lookup(ident *ast.Identifier, env *object.Environment) (object.Object, bool) {
+	if b := ident.Binding; b != nil {
+		return env.GetSlot(b.Depth, b.Slot)
+	}
+	return env.Get(ident.Value)
+}
+
+// define binds the variable of ident in env itself.
+func define(ident *ast.Identifier, val object.Object, env *object.Environment) {
+	if ident.Binding != nil {
+		env.SetSlot(ident.Binding.Slot, val)
+		return
+	}
+	env.Set(ident.Value, val)
+}
+
+// assign updates the variable ident refers to, which must be bound.
+func assign(ident *ast.Identifier, val object.Object, env *object.Environment) {
+	if b := ident.Binding; b != nil {
+		env.AssignSlot(b.Depth, b.Slot, val)
+		return
+	}
+	env.Assign(ident.Value, val)
+}
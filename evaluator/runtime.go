@@ -0,0 +1,154 @@
+package evaluator
+
+import (
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/token"
+)
+
+// The functions in this file expose the semantics of the evaluator to
+// other engines, such as the bytecode virtual machine, so that a program
+// behaves the same whichever engine runs it.
+
+// Engine runs programs for the interpreter, the prelude and imported
+// modules. It is Eval unless another engine is selected.
+var Engine func(node ast.Node, env *object.Environment) object.Object
+
+// Engine is set in init to avoid an initialization cycle, as it is called
+// by the import statement that Eval evaluates.
+func init() {
+	Engine = Eval
+}
+
+// Profiler is told about the calls of functions by the engines.
+type Profiler interface {
+	// Enter is called when a function is called, with the position of
+	// its definition, which is invalid for builtins.
+	Enter(name string, pos token.Position)
+	// Exit is called when the function entered last returns.
+	Exit()
+}
+
+// Profile, if set, is told about the function calls of the programs that
+// run.
+var Profile Profiler
+
+// Callable is a function of another engine. Builtins that take a function,
+// such as map, call it through Apply. pos is the call site.
+type Callable interface {
+	object.Object
+	Call(args []object.Object, pos token.Position) object.Object
+}
+
+// Resolve resolves the variables of program, which runs in env, and
+// returns the first error found.
+func Resolve(program *ast.Program, env *object.Environment) *object.Error {
+	return resolve(program, env)
+}
+
+// LookupBuiltin returns the builtin function called name.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// IsTruthy reports whether obj counts as true in a condition.
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// Prefix applies a prefix operator.
+func Prefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// Infix applies an infix operator other than && and ||.
+func Infix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// Index returns left[index].
+func Index(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// Slice returns left[start:stop:step]. Bounds are nil if omitted.
+func Slice(left, start, stop, step object.Object) object.Object {
+	return sliceObject(left, start, stop, step)
+}
+
+// Apply calls fn with args. pos is the call site.
+func Apply(fn object.Object, args []object.Object, pos token.Position) object.Object {
+	return applyFunction(fn, args, pos)
+}
+
+// ArityError returns an error if got arguments do not fit a function with
+// min to max parameters, or at least min if it has a rest parameter.
+func ArityError(min, max int, rest bool, got int) *object.Error {
+	return arityError(min, max, rest, got)
+}
+
+// ErrorToHash converts a caught error into the hash bound by catch.
+func ErrorToHash(err *object.Error) *object.Hash {
+	return errorToHash(err)
+}
+
+// Throw converts a thrown value into an error.
+func Throw(val object.Object) *object.Error {
+	return throwValue(val)
+}
+
+// CheckIndexAssignment returns an error if left[index] cannot be assigned.
+func CheckIndexAssignment(left, index object.Object) *object.Error {
+	return checkIndexAssignment(left, index)
+}
+
+// IndexedValue returns the current value of left[index] for a compound
+// assignment, or an error if it cannot be assigned.
+func IndexedValue(left, index object.Object) object.Object {
+	return indexedValue(left, index)
+}
+
+// SetIndex sets left[index] to val after CheckIndexAssignment.
+func SetIndex(left, index, val object.Object) {
+	setIndex(left, index, val)
+}
+
+// Member returns the member called name of a module.
+func Member(obj object.Object, name string) object.Object {
+	return member(obj, name)
+}
+
+// Import imports the module of is and binds it in env. It returns nil or
+// an error.
+func Import(is *ast.ImportStatement, env *object.Environment) object.Object {
+	return evalImportStatement(is, env)
+}
+
+// UnquoteArguments returns the arguments of the unquote calls in quoted in
+// the order Quote substitutes them.
+func UnquoteArguments(quoted ast.Node) []ast.Expression {
+	var args []ast.Expression
+	ast.Modify(quoted, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if ok && isUnquoteCall(call) && len(call.Arguments) == 1 {
+			args = append(args, call.Arguments[0])
+		}
+		return node
+	})
+	return args
+}
+
+// Quote returns node quoted, with the unquote calls replaced by values,
+// which are the values of the arguments from UnquoteArguments.
+func Quote(node ast.Node, values []object.Object) *object.Quote {
+	node = replaceUnquoteCalls(node, func(ast.Expression) object.Object {
+		if len(values) == 0 {
+			return nil
+		}
+		val := values[0]
+		values = values[1:]
+		return val
+	})
+	return &object.Quote{Node: node}
+}
@@ -0,0 +1,161 @@
+package evaluator
+
+import (
+	"math"
+
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/object"
+)
+
+// Slicing follows Python: negative bounds count from the end, bounds out
+// of range are clamped, and a negative step walks backwards.
+
+func evalSliceExpression(
+	node *ast.SliceExpression,
+	env *object.Environment,
+) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	bounds := make([]object.Object, 3)
+	for i, exp := range []ast.Expression{node.Start, node.Stop, node.Step} {
+		if exp == nil {
+			continue
+		}
+		bounds[i] = Eval(exp, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	return sliceObject(left, bounds[0], bounds[1], bounds[2])
+}
+
+// sliceObject slices an array or a string. Bounds are nil if omitted.
+func sliceObject(left, start, stop, step object.Object) object.Object {
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(len([]rune(left.Value)))
+	default:
+		return newError(object.TYPE_ERROR, "slice operator not supported: %s", left.Type())
+	}
+
+	var bounds [3]*int64
+	for i, b := range []object.Object{start, stop, step} {
+		if b == nil {
+			continue
+		}
+		v, err := sliceBound(b)
+		if err != nil {
+			return err
+		}
+		bounds[i] = &v
+	}
+
+	from, to, by, err := sliceIndices(bounds[0], bounds[1], bounds[2], length)
+	if err != nil {
+		return err
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := []object.Object{}
+		for i := from; by > 0 && i < to || by < 0 && i > to; i += by {
+			elements = append(elements, left.Elements[i])
+		}
+		return &object.Array{Elements: elements}
+	default:
+		runes := []rune(left.(*object.String).Value)
+		if by == 1 {
+			if to < from {
+				to = from
+			}
+			return &object.String{Value: string(runes[from:to])}
+		}
+		sliced := []rune{}
+		for i := from; by > 0 && i < to || by < 0 && i > to; i += by {
+			sliced = append(sliced, runes[i])
+		}
+		return &object.String{Value: string(sliced)}
+	}
+}
+
+// sliceBound converts a slice bound into an int64. A BigInteger is out
+// of range of any array, so it is clamped.
+func sliceBound(obj object.Object) (int64, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.BigInteger:
+		if obj.Value.Sign() < 0 {
+			return math.MinInt64, nil
+		}
+		return math.MaxInt64, nil
+	default:
+		return 0, newError(object.TYPE_ERROR, "slice index must be INTEGER, got %s", obj.Type())
+	}
+}
+
+// sliceIndices resolves optional slice bounds for a sequence of the given
+// length into the first index, the index to stop before, and the step.
+func sliceIndices(start, stop, step *int64, length int64) (int64, int64, int64, *object.Error) {
+	by := int64(1)
+	if step != nil {
+		by = *step
+	}
+	if by == 0 {
+		return 0, 0, 0, newError(object.VALUE_ERROR, "slice step cannot be zero")
+	}
+	// a step longer than the sequence selects at most one element, and
+	// shortening it keeps the index arithmetic from overflowing
+	if by > length {
+		by = length + 1
+	} else if by < -length {
+		by = -length - 1
+	}
+
+	// the range of valid indices, widened by one in the direction of travel
+	lower, upper := int64(0), length
+	if by < 0 {
+		lower, upper = -1, length-1
+	}
+
+	resolve := func(bound *int64, omitted int64) int64 {
+		if bound == nil {
+			return omitted
+		}
+		i := *bound
+		if i < 0 {
+			i += length
+			if i < lower {
+				i = lower
+			}
+		} else if i > upper {
+			i = upper
+		}
+		return i
+	}
+
+	if by > 0 {
+		return resolve(start, lower), resolve(stop, upper), by, nil
+	}
+	return resolve(start, upper), resolve(stop, lower), by, nil
+}
+
+// _slice is the function form of x[start:stop].
+func _slice(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 2, 3); err != nil {
+		return err
+	}
+
+	var stop object.Object
+	if len(args) == 3 {
+		stop = args[2]
+	}
+	return sliceObject(args[0], args[1], stop, nil)
+}
@@ -0,0 +1,328 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tatsuya4559/monkey/object"
+)
+
+// String builtins count indices and lengths in runes, not bytes.
+
+var ordinals = []string{"first", "second", "third", "fourth"}
+
+// checkArgCount returns an error unless min <= len(args) <= max.
+func checkArgCount(args []object.Object, min, max int) *object.Error {
+	if len(args) >= min && len(args) <= max {
+		return nil
+	}
+	if min == max {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=%d, got=%d",
+			min, len(args))
+	}
+	return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=%d to %d, got=%d",
+		min, max, len(args))
+}
+
+func argumentName(args []object.Object, i int) string {
+	if len(args) == 1 {
+		return "argument"
+	}
+	if i >= len(ordinals) {
+		return fmt.Sprintf("argument %d", i+1)
+	}
+	return ordinals[i] + " argument"
+}
+
+// stringArg returns the i-th argument of the builtin name as a string.
+func stringArg(name string, args []object.Object, i int) (string, *object.Error) {
+	str, ok := args[i].(*object.String)
+	if !ok {
+		return "", newError(object.TYPE_ERROR, "%s to `%s` must be STRING, got %s",
+			argumentName(args, i), name, args[i].Type())
+	}
+	return str.Value, nil
+}
+
+// integerArg returns the i-th argument of the builtin name as an int64.
+func integerArg(name string, args []object.Object, i int) (int64, *object.Error) {
+	switch arg := args[i].(type) {
+	case *object.Integer:
+		return arg.Value, nil
+	case *object.BigInteger:
+		return 0, newError(object.VALUE_ERROR, "%s to `%s` out of range: %s",
+			argumentName(args, i), name, arg.Inspect())
+	default:
+		return 0, newError(object.TYPE_ERROR, "%s to `%s` must be INTEGER, got %s",
+			argumentName(args, i), name, arg.Type())
+	}
+}
+
+// runeIndex converts a byte index in s into a rune index.
+func runeIndex(s string, byteIndex int) int {
+	if byteIndex < 0 {
+		return byteIndex
+	}
+	return utf8.RuneCountInString(s[:byteIndex])
+}
+
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		// a BigInteger is always out of range
+		return NULL
+	}
+	idx := integer.Value
+	length := int64(len(runes))
+
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+func stringsToArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// split splits a string by a separator, or around whitespace if the
+// separator is omitted.
+func _split(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+
+	s, err := stringArg("split", args, 0)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		return stringsToArray(strings.Fields(s))
+	}
+
+	sep, err := stringArg("split", args, 1)
+	if err != nil {
+		return err
+	}
+	return stringsToArray(strings.Split(s, sep))
+}
+
+// join concatenates the elements of an array. Elements that are not
+// strings are joined in their printed form.
+func _join(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(object.TYPE_ERROR, "%s to `join` must be ARRAY, got %s",
+			argumentName(args, 0), args[0].Type())
+	}
+
+	var sep string
+	if len(args) == 2 {
+		var err *object.Error
+		if sep, err = stringArg("join", args, 1); err != nil {
+			return err
+		}
+	}
+
+	strs := make([]string, len(arr.Elements))
+	for i, e := range arr.Elements {
+		if str, ok := e.(*object.String); ok {
+			strs[i] = str.Value
+		} else {
+			strs[i] = e.Inspect()
+		}
+	}
+	return &object.String{Value: strings.Join(strs, sep)}
+}
+
+// trim removes leading and trailing whitespace, or the characters in
+// cutset if given.
+func _trim(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+
+	s, err := stringArg("trim", args, 0)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		return &object.String{Value: strings.TrimSpace(s)}
+	}
+
+	cutset, err := stringArg("trim", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Trim(s, cutset)}
+}
+
+func _upper(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return err
+	}
+
+	s, err := stringArg("upper", args, 0)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToUpper(s)}
+}
+
+func _lower(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return err
+	}
+
+	s, err := stringArg("lower", args, 0)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToLower(s)}
+}
+
+// replace replaces all occurrences of old with new, or the first n if
+// n is given.
+func _replace(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 3, 4); err != nil {
+		return err
+	}
+
+	strs := make([]string, 3)
+	for i := range strs {
+		var err *object.Error
+		if strs[i], err = stringArg("replace", args, i); err != nil {
+			return err
+		}
+	}
+
+	n := int64(-1)
+	if len(args) == 4 {
+		var err *object.Error
+		if n, err = integerArg("replace", args, 3); err != nil {
+			return err
+		}
+	}
+	return &object.String{Value: strings.Replace(strs[0], strs[1], strs[2], int(n))}
+}
+
+// stringPredicate returns a builtin that applies fn to two strings.
+func stringPredicate(name string, fn func(s, substr string) bool) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if err := checkArgCount(args, 2, 2); err != nil {
+			return err
+		}
+
+		s, err := stringArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+		substr, err := stringArg(name, args, 1)
+		if err != nil {
+			return err
+		}
+		return nativeBoolToBooleanObject(fn(s, substr))
+	}
+}
+
+// _stringIndexOf returns the rune index of the first occurrence of substr,
+// or -1 if it is not present.
+func _stringIndexOf(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+
+	s, err := stringArg("index_of", args, 0)
+	if err != nil {
+		return err
+	}
+	substr, err := stringArg("index_of", args, 1)
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: int64(runeIndex(s, strings.Index(s, substr)))}
+}
+
+// maxStringLength bounds the strings that repeat builds.
+const maxStringLength = 1 << 30
+
+func _repeat(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+
+	s, err := stringArg("repeat", args, 0)
+	if err != nil {
+		return err
+	}
+	count, err := integerArg("repeat", args, 1)
+	if err != nil {
+		return err
+	}
+	if count < 0 {
+		return newError(object.VALUE_ERROR, "negative repeat count: %d", count)
+	}
+	if len(s) > 0 && count > int64(maxStringLength/len(s)) {
+		return newError(object.VALUE_ERROR, "repeat count too large: %d", count)
+	}
+	return &object.String{Value: strings.Repeat(s, int(count))}
+}
+
+func _chars(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return err
+	}
+
+	s, err := stringArg("chars", args, 0)
+	if err != nil {
+		return err
+	}
+	return stringsToArray(strings.Split(s, ""))
+}
+
+// format formats its arguments like Go's fmt.Sprintf. Integers, floats,
+// strings and booleans are passed as the corresponding Go values and
+// other objects as their printed form.
+func _format(args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. want=at least 1, got=%d",
+			len(args))
+	}
+
+	format, err := stringArg("format", args, 0)
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(args)-1)
+	for i, arg := range args[1:] {
+		switch arg := arg.(type) {
+		case *object.Integer:
+			values[i] = arg.Value
+		case *object.BigInteger:
+			values[i] = arg.Value
+		case *object.Float:
+			values[i] = arg.Value
+		case *object.String:
+			values[i] = arg.Value
+		case *object.Boolean:
+			values[i] = arg.Value
+		default:
+			values[i] = arg.Inspect()
+		}
+	}
+	return &object.String{Value: fmt.Sprintf(format, values...)}
+}
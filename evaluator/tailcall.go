@@ -0,0 +1,64 @@
+package evaluator
+
+import (
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/token"
+)
+
+// Calls in tail position, which the parser marks, are not applied where
+// they are evaluated. They evaluate to a tailCall, which applyFunction
+// applies in a loop after the calling function returns, so that tail
+// recursion does not grow the Go stack.
+
+// tailCall is a call to apply once the function that evaluated it returns.
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+	pos  token.Position
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// maxTailCallers is the number of the last functions that made tail calls
+// whose frames are kept for stack traces.
+const maxTailCallers = 8
+
+// TailCallers records the frames of the functions that made tail calls
+// after a call, the first one. It keeps the frames of the first and of the
+// last maxTailCallers functions, so that a loop of tail calls does not
+// grow the stack trace.
+type TailCallers struct {
+	first  object.Frame
+	last   []object.Frame // a ring starting at next once full
+	next   int
+	frames int
+}
+
+// Add records the frame of a function that made a tail call.
+func (tc *TailCallers) Add(frame object.Frame) {
+	tc.frames++
+	switch {
+	case tc.frames == 1:
+		tc.first = frame
+	case len(tc.last) < maxTailCallers:
+		tc.last = append(tc.last, frame)
+	default:
+		tc.last[tc.next] = frame
+		tc.next = (tc.next + 1) % maxTailCallers
+	}
+}
+
+// AppendTo appends the frames to the stack trace of err, innermost first.
+func (tc *TailCallers) AppendTo(err *object.Error) *object.Error {
+	if tc == nil || tc.frames == 0 {
+		return err
+	}
+	for i := len(tc.last) - 1; i >= 0; i-- {
+		err.Trace = append(err.Trace, tc.last[(tc.next+i)%len(tc.last)])
+	}
+	first := tc.first
+	first.TailCalls = tc.frames - 1 - len(tc.last)
+	err.Trace = append(err.Trace, first)
+	return err
+}
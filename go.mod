@@ -1,3 +1,3 @@
 module github.com/tatsuya4559/monkey
 
-go 1.14
+go 1.16
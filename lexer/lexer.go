@@ -1,7 +1,11 @@
 package lexer
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/tatsuya4559/monkey/token"
 )
@@ -11,15 +15,35 @@ type Lexer struct {
 	position     int  // current reading position
 	readPosition int  // next position to read
 	ch           rune // current reading character
+
+	filename string
+	line     int // line of current reading character
+	column   int // column of current reading character
+
+	// interpolations holds the number of unclosed braces in each ${
+	// being read, innermost last.
+	interpolations []int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: []rune(input)}
+	return NewFile("", input)
+}
+
+// NewFile returns a lexer whose token positions refer to filename.
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{input: []rune(input), filename: filename, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -29,11 +53,23 @@ func (l *Lexer) readChar() {
 	l.readPosition++
 }
 
-func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+func (l *Lexer) curPosition() token.Position {
+	return token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+}
 
+func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespace()
 
+	pos := l.curPosition()
+	tok := l.readToken()
+	tok.Pos = pos
+	tok.End = l.curPosition()
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -48,6 +84,14 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekNthChar(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -55,20 +99,20 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.newTokenWithAssign(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.newTokenWithAssign(token.MINUS, token.MINUS_ASSIGN)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		tok = l.newTokenWithAssign(token.ASTERISK, token.ASTERISK_ASSIGN)
 	case '/':
 		if l.peekChar() == '/' {
 			tok.Literal = l.readComment()
 			tok.Type = token.COMMENT
 		} else {
-			tok = newToken(token.SLASH, l.ch)
+			tok = l.newTokenWithAssign(token.SLASH, token.SLASH_ASSIGN)
 		}
 	case '%':
-		tok = newToken(token.MOD, l.ch)
+		tok = l.newTokenWithAssign(token.MOD, token.MOD_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -79,13 +123,71 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.LT_EQ, Literal: literal}
+		} else if l.peekChar() == '<' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.LSHIFT, Literal: literal}
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.GT_EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.RSHIFT, Literal: literal}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.AND, Literal: literal}
+		} else {
+			tok = newToken(token.BIT_AND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.OR, Literal: literal}
+		} else {
+			tok = newToken(token.BIT_OR, l.ch)
+		}
+	case '^':
+		tok = newToken(token.BIT_XOR, l.ch)
+	case '~':
+		tok = newToken(token.BIT_NOT, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		if n := len(l.interpolations); n > 0 && l.interpolations[n-1] == 0 {
+			// end of ${...}, so the string continues
+			l.interpolations = l.interpolations[:n-1]
+			tok = l.readString(token.STRING_MID, token.STRING_TAIL)
+		} else {
+			if n > 0 {
+				l.interpolations[n-1]--
+			}
+			tok = newToken(token.RBRACE, l.ch)
+		}
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -94,19 +196,19 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = ""
 		tok.Type = token.EOF
 	case '"':
-		tok.Literal = l.readString()
-		tok.Type = token.STRING
+		tok = l.readString(token.STRING_HEAD, token.STRING)
+	case '`':
+		tok = l.readRawString()
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = illegal("unexpected character %q", l.ch)
 		}
 	}
 
@@ -118,10 +220,38 @@ func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
+func illegal(format string, args ...interface{}) token.Token {
+	return token.Token{Type: token.ILLEGAL, Literal: fmt.Sprintf(format, args...)}
+}
+
+// newTokenWithAssign returns a compound assignment token such as += if the
+// operator is followed by =.
+func (l *Lexer) newTokenWithAssign(op, opAssign token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: opAssign, Literal: string(ch) + string(l.ch)}
+	}
+	return newToken(op, l.ch)
+}
+
 func isLetter(ch rune) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
+func isBasePrefix(ch rune) bool {
+	switch ch {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return true
+	default:
+		return false
+	}
+}
+
+func isHexDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 func isDigit(ch rune) bool {
 	return unicode.IsDigit(ch)
 }
@@ -140,30 +270,163 @@ func (l *Lexer) readIdentifier() string {
 	return string(l.input[position:l.position])
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or a float literal such as 3.14 or 1e-9.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
-	for isDigit(l.ch) {
+	var tokenType token.TokenType = token.INT
+
+	if l.ch == '0' && isBasePrefix(l.peekChar()) {
+		// 0x, 0o or 0b; the parser validates the digits
+		l.readChar()
 		l.readChar()
+		for isHexDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+		return string(l.input[position:l.position]), tokenType
 	}
-	return string(l.input[position:l.position])
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || (next == '+' || next == '-') && isDigit(l.peekNthChar(2)) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return string(l.input[position:l.position]), tokenType
 }
 
-func (l *Lexer) readString() string {
+// readDigits reads decimal digits and _ separators.
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// readString reads a double-quoted string, or the rest of one after an
+// interpolated expression, and decodes its escape sequences. It returns
+// a token of type interpolated if the string stops at ${, or closed if
+// it stops at the closing quote. The current character is left on the
+// last character of the token.
+func (l *Lexer) readString(interpolated, closed token.TokenType) token.Token {
+	var out strings.Builder
+	var errMsg string
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case 0:
+			return illegal("unterminated string")
+		case '"':
+			if errMsg != "" {
+				return illegal("%s", errMsg)
+			}
+			return token.Token{Type: closed, Literal: out.String()}
+		case '$':
+			if l.peekChar() != '{' {
+				out.WriteRune(l.ch)
+				continue
+			}
+			l.readChar()
+			l.interpolations = append(l.interpolations, 0)
+			if errMsg != "" {
+				return illegal("%s", errMsg)
+			}
+			return token.Token{Type: interpolated, Literal: out.String()}
+		case '\\':
+			l.readChar()
+			if l.ch == 0 {
+				return illegal("unterminated string")
+			}
+			// report the first bad escape once the string is read
+			if msg := l.readEscape(&out); msg != "" && errMsg == "" {
+				errMsg = msg
+			}
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// readEscape decodes the escape sequence after a backslash into out.
+// It returns an error message if the sequence is invalid.
+func (l *Lexer) readEscape(out *strings.Builder) string {
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '"', '\\', '$':
+		out.WriteRune(l.ch)
+	case 'u':
+		if l.peekChar() != '{' {
+			return "invalid unicode escape: missing {"
+		}
+		l.readChar()
+
+		var digits strings.Builder
+		for isHexDigit(l.peekChar()) {
+			l.readChar()
+			digits.WriteRune(l.ch)
+		}
+		if l.peekChar() != '}' {
+			return "invalid unicode escape: missing }"
+		}
+		l.readChar()
+
+		code, err := strconv.ParseUint(digits.String(), 16, 32)
+		if err != nil || digits.Len() > 6 || !utf8.ValidRune(rune(code)) {
+			return fmt.Sprintf("invalid unicode escape: \\u{%s}", digits.String())
+		}
+		out.WriteRune(rune(code))
+	default:
+		return fmt.Sprintf("unknown escape sequence: \\%c", l.ch)
+	}
+
+	return ""
+}
+
+// readRawString reads a backquoted string. Raw strings may span lines
+// and have no escape sequences or interpolation.
+func (l *Lexer) readRawString() token.Token {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case 0:
+			return illegal("unterminated raw string")
+		case '`':
+			return token.Token{Type: token.STRING, Literal: string(l.input[position:l.position])}
 		}
 	}
-	return string(l.input[position:l.position])
 }
 
 func (l *Lexer) peekChar() rune {
-	if l.readPosition >= len(l.input) {
+	return l.peekNthChar(1)
+}
+
+// peekNthChar returns the n-th character after the current one.
+func (l *Lexer) peekNthChar(n int) rune {
+	pos := l.position + n
+	if pos >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPosition]
+	return l.input[pos]
 }
 
 func (l *Lexer) readComment() string {
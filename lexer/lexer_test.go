@@ -34,6 +34,16 @@ macro(x, y) { x + y; };
 while (true) { puts("foo") }; // comment
 // 日本語コメント
 12 % 3;
+try { throw "x" } catch (e) { } finally { }
+3.14 1e-9 2.5E+3 1e;
+fn(...rest) { f(...rest) }
+x += 1; x -= 1; x *= 2; x /= 2; x %= 2;
+break; continue;
+for k, v in x {}
+a <= b >= c && d || e;
+a & b | c ^ ~d << 0xFF >> 0b1_0;
+0o17 1_000 1_000.5;
+import "m.mnk" as m; export let x = m.y;
 `
 
 	tests := []struct {
@@ -156,6 +166,110 @@ while (true) { puts("foo") }; // comment
 		{token.MOD, "%"},
 		{token.INT, "3"},
 		{token.SEMICOLON, ";"},
+		{token.TRY, "try"},
+		{token.LBRACE, "{"},
+		{token.THROW, "throw"},
+		{token.STRING, "x"},
+		{token.RBRACE, "}"},
+		{token.CATCH, "catch"},
+		{token.LPAREN, "("},
+		{token.IDENT, "e"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.FINALLY, "finally"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.INT, "1"},
+		{token.IDENT, "e"},
+		{token.SEMICOLON, ";"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MOD_ASSIGN, "%="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.BREAK, "break"},
+		{token.SEMICOLON, ";"},
+		{token.CONTINUE, "continue"},
+		{token.SEMICOLON, ";"},
+		{token.FOR, "for"},
+		{token.IDENT, "k"},
+		{token.COMMA, ","},
+		{token.IDENT, "v"},
+		{token.IN, "in"},
+		{token.IDENT, "x"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.IDENT, "e"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.BIT_AND, "&"},
+		{token.IDENT, "b"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "c"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.IDENT, "d"},
+		{token.LSHIFT, "<<"},
+		{token.INT, "0xFF"},
+		{token.RSHIFT, ">>"},
+		{token.INT, "0b1_0"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "0o17"},
+		{token.INT, "1_000"},
+		{token.FLOAT, "1_000.5"},
+		{token.SEMICOLON, ";"},
+		{token.IMPORT, "import"},
+		{token.STRING, "m.mnk"},
+		{token.AS, "as"},
+		{token.IDENT, "m"},
+		{token.SEMICOLON, ";"},
+		{token.EXPORT, "export"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "m"},
+		{token.DOT, "."},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 
@@ -175,3 +289,131 @@ while (true) { puts("foo") }; // comment
 		}
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := `let x = "バー";
+	x == 10;`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedPos     token.Position
+		expectedEnd     token.Position
+	}{
+		{"let", token.Position{Filename: "a.mnk", Line: 1, Column: 1}, token.Position{Filename: "a.mnk", Line: 1, Column: 4}},
+		{"x", token.Position{Filename: "a.mnk", Line: 1, Column: 5}, token.Position{Filename: "a.mnk", Line: 1, Column: 6}},
+		{"=", token.Position{Filename: "a.mnk", Line: 1, Column: 7}, token.Position{Filename: "a.mnk", Line: 1, Column: 8}},
+		{"バー", token.Position{Filename: "a.mnk", Line: 1, Column: 9}, token.Position{Filename: "a.mnk", Line: 1, Column: 13}},
+		{";", token.Position{Filename: "a.mnk", Line: 1, Column: 13}, token.Position{Filename: "a.mnk", Line: 1, Column: 14}},
+		{"x", token.Position{Filename: "a.mnk", Line: 2, Column: 2}, token.Position{Filename: "a.mnk", Line: 2, Column: 3}},
+		{"==", token.Position{Filename: "a.mnk", Line: 2, Column: 4}, token.Position{Filename: "a.mnk", Line: 2, Column: 6}},
+		{"10", token.Position{Filename: "a.mnk", Line: 2, Column: 7}, token.Position{Filename: "a.mnk", Line: 2, Column: 9}},
+	}
+
+	l := NewFile("a.mnk", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("tests[%d] - pos wrong. expected=%s, got=%s",
+				i, tt.expectedPos, tok.Pos)
+		}
+
+		if tok.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%s, got=%s",
+				i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{
+			`"a\tb\n\"c\" \\ \${d} \u{65e5}\u{1F600}"`,
+			[]token.Token{{Type: token.STRING, Literal: "a\tb\n\"c\" \\ ${d} 日😀"}},
+		},
+		{
+			"`raw \\n \"${x}\"\nline`",
+			[]token.Token{{Type: token.STRING, Literal: "raw \\n \"${x}\"\nline"}},
+		},
+		{
+			`"Hello ${name}!"`,
+			[]token.Token{
+				{Type: token.STRING_HEAD, Literal: "Hello "},
+				{Type: token.IDENT, Literal: "name"},
+				{Type: token.STRING_TAIL, Literal: "!"},
+			},
+		},
+		{
+			`"${ {"a": 1}["a"] }-${"in${x}"}"`,
+			[]token.Token{
+				{Type: token.STRING_HEAD, Literal: ""},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.STRING, Literal: "a"},
+				{Type: token.COLON, Literal: ":"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.RBRACE, Literal: "}"},
+				{Type: token.LBRACKET, Literal: "["},
+				{Type: token.STRING, Literal: "a"},
+				{Type: token.RBRACKET, Literal: "]"},
+				{Type: token.STRING_MID, Literal: "-"},
+				{Type: token.STRING_HEAD, Literal: "in"},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.STRING_TAIL, Literal: ""},
+				{Type: token.STRING_TAIL, Literal: ""},
+			},
+		},
+		{
+			`"abc`,
+			[]token.Token{{Type: token.ILLEGAL, Literal: "unterminated string"}},
+		},
+		{
+			"`abc",
+			[]token.Token{{Type: token.ILLEGAL, Literal: "unterminated raw string"}},
+		},
+		{
+			`"a\qb" 1`,
+			[]token.Token{
+				{Type: token.ILLEGAL, Literal: "unknown escape sequence: \\q"},
+				{Type: token.INT, Literal: "1"},
+			},
+		},
+		{
+			`"\u{110000}"`,
+			[]token.Token{{Type: token.ILLEGAL, Literal: "invalid unicode escape: \\u{110000}"}},
+		},
+		{
+			`"\u{41"`,
+			[]token.Token{{Type: token.ILLEGAL, Literal: "invalid unicode escape: missing }"}},
+		},
+		{
+			"@",
+			[]token.Token{{Type: token.ILLEGAL, Literal: "unexpected character '@'"}},
+		},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Errorf("%s: tokens[%d] wrong. expected=%s %q, got=%s %q",
+					tt.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%s: expected EOF, got=%s %q", tt.input, tok.Type, tok.Literal)
+		}
+	}
+}
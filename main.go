@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -8,18 +9,49 @@ import (
 	"os"
 	"os/user"
 
+	"github.com/tatsuya4559/monkey/ast"
 	"github.com/tatsuya4559/monkey/evaluator"
 	"github.com/tatsuya4559/monkey/lexer"
 	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/optimizer"
 	"github.com/tatsuya4559/monkey/parser"
+	"github.com/tatsuya4559/monkey/prelude"
+	"github.com/tatsuya4559/monkey/profiler"
 	"github.com/tatsuya4559/monkey/repl"
+	"github.com/tatsuya4559/monkey/vm"
+)
+
+var (
+	noPrelude = flag.Bool("no-prelude", false, "do not load the prelude")
+	engine    = flag.String("engine", "eval", "run programs with `engine`: eval or vm")
+	optimize  = flag.Bool("optimize", false, "fold constants and remove dead branches before running")
+	printAST  = flag.Bool("print-ast", false, "print the program to run instead of running it")
+	profile   = flag.String("profile", "", "write a pprof profile of the program to `file` and print a summary")
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [file]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch *engine {
+	case "eval":
+	case "vm":
+		evaluator.Engine = vm.Run
+	default:
+		log.Fatalf("unknown engine: %s", *engine)
+	}
+
+	if !*noPrelude {
+		evaluator.Prelude = prelude.Load
+	}
+
+	if flag.NArg() < 1 {
 		startREPL()
 	} else {
-		interpretFile(os.Args[1])
+		interpretFile(flag.Arg(0))
 	}
 }
 
@@ -40,25 +72,77 @@ func interpretFile(filename string) {
 		log.Fatalf("cannot read %s: %v", filename, err)
 	}
 
-	l := lexer.New(string(src))
+	l := lexer.NewFile(filename, string(src))
 	p := parser.New(l)
-	program, err := p.ParseProgram()
-	if err != nil {
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
 		io.WriteString(os.Stderr, "Woops! We ran into some monkey business here!\n")
-		io.WriteString(os.Stderr, " parser error:\n")
-		io.WriteString(os.Stderr, "\t"+err.Error()+"\n")
+		io.WriteString(os.Stderr, " parser errors:\n")
+		for _, err := range errs {
+			io.WriteString(os.Stderr, "\t"+err.Error()+"\n")
+		}
 		os.Exit(1)
 	}
 
 	env := object.NewEnvironment()
 	macroEnv := object.NewEnvironment()
 
+	if errObj := evaluator.LoadPrelude(env, macroEnv); errObj != nil {
+		exitWithError(errObj)
+	}
+	if errObj := evaluator.SetMain(filename, program, env, macroEnv); errObj != nil {
+		exitWithError(errObj)
+	}
+	if errObj := evaluator.ImportMacros(program, env, macroEnv); errObj != nil {
+		exitWithError(errObj)
+	}
 	evaluator.DefineMacros(program, macroEnv)
 	expanded := evaluator.ExpandMacros(program, macroEnv)
+	if *optimize {
+		expanded = optimizer.Optimize(expanded.(*ast.Program))
+	}
+	if *printAST {
+		for _, stmt := range expanded.(*ast.Program).Statements {
+			io.WriteString(os.Stdout, stmt.String()+"\n")
+		}
+		return
+	}
 
-	evaluated := evaluator.Eval(expanded, env)
+	var prof *profiler.Profiler
+	if *profile != "" {
+		prof = profiler.New()
+		evaluator.Profile = prof
+	}
+	evaluated := evaluator.Engine(expanded, env)
+	if prof != nil {
+		writeProfile(prof, *profile)
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		exitWithError(errObj)
+	}
 	if evaluated != nil {
 		io.WriteString(os.Stdout, evaluated.Inspect())
 		io.WriteString(os.Stdout, "\n")
 	}
 }
+
+func writeProfile(prof *profiler.Profiler, filename string) {
+	prof.Stop()
+	evaluator.Profile = nil
+
+	f, err := os.Create(filename)
+	if err != nil {
+		log.Fatalf("cannot write profile: %v", err)
+	}
+	defer f.Close()
+	if err := prof.WritePprof(f); err != nil {
+		log.Fatalf("cannot write profile: %v", err)
+	}
+	prof.WriteTable(os.Stderr)
+}
+
+func exitWithError(errObj *object.Error) {
+	io.WriteString(os.Stderr, errObj.Inspect()+"\n")
+	io.WriteString(os.Stderr, errObj.StackTrace())
+	os.Exit(1)
+}
@@ -4,19 +4,28 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/token"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	STRING_OBJ       = "STRING"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	RANGE_OBJ        = "RANGE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	BUILTIN_OBJ      = "BUILTIN"
@@ -24,6 +33,7 @@ const (
 	HASH_OBJ         = "HASH"
 	QUOTE_OBJ        = "QUOTE"
 	MACRO_OBJ        = "MACRO"
+	MODULE_OBJ       = "MODULE"
 )
 
 type Object interface {
@@ -31,15 +41,17 @@ type Object interface {
 	Inspect() string
 }
 
+// Environment holds variables. Global variables and those of unresolved
+// code are stored by name; the resolver assigns the others to slots.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	slots   []Object
+	outer   *Environment
+	modules *Modules // set on the outermost environment only
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	return &Environment{outer: outer}
 }
 
 func NewEnvironment() *Environment {
@@ -56,10 +68,98 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
 
+// Modules returns the modules of the interpreter that e belongs to.
+func (e *Environment) Modules() *Modules {
+	for e.outer != nil {
+		e = e.outer
+	}
+	if e.modules == nil {
+		e.modules = &Modules{Loaded: make(map[string]*Module)}
+	}
+	return e.modules
+}
+
+// NewModuleEnvironment returns the environment of a module imported by the
+// interpreter that modules belongs to.
+func NewModuleEnvironment(modules *Modules) *Environment {
+	env := NewEnvironment()
+	env.modules = modules
+	return env
+}
+
+// Names returns the names bound in e itself, not in its outer
+// environments, in sorted order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Assign updates the nearest enclosing binding of name.
+// It reports false if name is not bound.
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
+
+// unboundValue marks a slot whose variable is not bound yet. It is not
+// zero-size, so that no other pointer can compare equal to unbound.
+type unboundValue struct{ _ byte }
+
+func (u *unboundValue) Type() ObjectType { return "UNBOUND" }
+func (u *unboundValue) Inspect() string  { return "unbound" }
+
+// unbound fills the slots whose variables are not bound yet.
+var unbound Object = &unboundValue{}
+
+// GetSlot returns the variable in slot of the environment depth levels
+// out. It reports false if the variable is not bound.
+func (e *Environment) GetSlot(depth, slot int) (Object, bool) {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	if slot >= len(e.slots) || e.slots[slot] == unbound {
+		return nil, false
+	}
+	return e.slots[slot], true
+}
+
+// SetSlot binds the variable in slot of e itself.
+func (e *Environment) SetSlot(slot int, val Object) {
+	for slot >= len(e.slots) {
+		e.slots = append(e.slots, unbound)
+	}
+	e.slots[slot] = val
+}
+
+// AssignSlot updates the variable in slot of the environment depth levels
+// out. It reports false if the variable is not bound.
+func (e *Environment) AssignSlot(depth, slot int, val Object) bool {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	if slot >= len(e.slots) || e.slots[slot] == unbound {
+		return false
+	}
+	e.slots[slot] = val
+	return true
+}
+
 type Integer struct {
 	Value int64
 }
@@ -70,11 +170,103 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 func (i *Integer) EqualsTo(o Object) bool {
-	other, ok := o.(*Integer)
-	if !ok {
+	switch other := o.(type) {
+	case *Integer:
+		return i.Value == other.Value
+	case *BigInteger:
+		return other.EqualsTo(i)
+	case *Float:
+		return i.equalsFloat(other.Value)
+	default:
+		return false
+	}
+}
+
+// equalsFloat reports whether f is exactly the value of i, which converting
+// i to float64 would round.
+func (i *Integer) equalsFloat(f float64) bool {
+	return f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 &&
+		int64(f) == i.Value
+}
+
+// BigInteger is an integer that does not fit in int64. It is an INTEGER
+// for Monkey code; arithmetic on Integers promotes to it on overflow.
+// Use NewInteger to create one so that small values stay Integers.
+type BigInteger struct {
+	Value *big.Int
+}
+
+// NewInteger returns an Integer if x fits in int64, or a BigInteger.
+func NewInteger(x *big.Int) Object {
+	if x.IsInt64() {
+		return &Integer{Value: x.Int64()}
+	}
+	return &BigInteger{Value: x}
+}
+
+func (b *BigInteger) Type() ObjectType { return INTEGER_OBJ }
+func (b *BigInteger) Inspect() string  { return b.Value.String() }
+func (b *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	if b.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(b.Value.Bytes())
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+func (b *BigInteger) EqualsTo(o Object) bool {
+	switch other := o.(type) {
+	case *BigInteger:
+		return b.Value.Cmp(other.Value) == 0
+	case *Integer:
+		return b.Value.Cmp(big.NewInt(other.Value)) == 0
+	case *Float:
+		if math.IsNaN(other.Value) {
+			return false
+		}
+		return new(big.Float).SetInt(b.Value).Cmp(big.NewFloat(other.Value)) == 0
+	default:
+		return false
+	}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eInN") {
+		// keep it distinguishable from an integer
+		s += ".0"
+	}
+	return s
+}
+
+// HashKey returns the key of the equal integer for integral values,
+// so that 1 and 1.0 refer to the same hash entry.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && !math.IsInf(f.Value, 0) {
+		if f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+			return (&Integer{Value: int64(f.Value)}).HashKey()
+		}
+		i, _ := big.NewFloat(f.Value).Int(nil)
+		return (&BigInteger{Value: i}).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+func (f *Float) EqualsTo(o Object) bool {
+	switch other := o.(type) {
+	case *Float:
+		return f.Value == other.Value
+	case *Integer:
+		return other.equalsFloat(f.Value)
+	case *BigInteger:
+		return other.EqualsTo(f)
+	default:
 		return false
 	}
-	return i.Value == other.Value
 }
 
 type String struct {
@@ -127,27 +319,99 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+type ErrorKind string
+
+const (
+	TYPE_ERROR          = "TypeError"
+	NAME_ERROR          = "NameError"
+	ARGUMENT_ERROR      = "ArgumentError"
+	VALUE_ERROR         = "ValueError"
+	INDEX_ERROR         = "IndexError"
+	KEY_ERROR           = "KeyError"
+	ZERO_DIVISION_ERROR = "ZeroDivisionError"
+	IMPORT_ERROR        = "ImportError"
+	RUNTIME_ERROR       = "RuntimeError" // internal failure of the interpreter
+	USER_ERROR          = "Error"        // raised by throw
+)
+
+// Break and Continue signal break and continue statements to the
+// enclosing loop, as ReturnValue does to the enclosing function.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
+	Kind    ErrorKind
 	Message string
+	Pos     token.Position // position of the expression that failed
+	Trace   []Frame        // function calls active at the time, innermost first
+}
+
+// Frame is a call of a Monkey function.
+type Frame struct {
+	Function string // empty for anonymous functions
+	Pos      token.Position
+
+	// TailCalls is the number of the functions called in tail position
+	// after this one whose frames are left out
+	TailCalls int
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("ERROR: ")
+	if e.Pos.IsValid() {
+		out.WriteString(e.Pos.String() + ": ")
+	}
+	if e.Kind != "" {
+		out.WriteString(string(e.Kind) + ": ")
+	}
+	out.WriteString(e.Message)
+
+	return out.String()
+}
+
+// StackTrace formats Trace one call per line.
+func (e *Error) StackTrace() string {
+	var out bytes.Buffer
+
+	for _, f := range e.Trace {
+		name := f.Function
+		if name == "" {
+			name = "<anonymous>"
+		}
+		if f.TailCalls > 0 {
+			fmt.Fprintf(&out, "\t... %d tail calls\n", f.TailCalls)
+		}
+		fmt.Fprintf(&out, "\tat %s (%s)\n", name, f.Pos)
+	}
+
+	return out.String()
+}
 
 type Function struct {
+	Name       string // name of the let binding, if any
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Pos        token.Position // of the function literal
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
+	params := ast.FormatParameters(f.Parameters, f.Defaults, f.Rest)
 
 	out.WriteString("fn")
 	out.WriteString("(")
@@ -162,7 +426,8 @@ func (f *Function) Inspect() string {
 type BuiltinFunction func(args ...Object) Object
 
 type Builtin struct {
-	Fn BuiltinFunction
+	Name string
+	Fn   BuiltinFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -206,6 +471,44 @@ func (a *Array) EqualsTo(o Object) bool {
 	return true
 }
 
+// Range is a lazy arithmetic sequence from Start up to, but not
+// including, Stop.
+type Range struct {
+	Start int64
+	Stop  int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+func (r *Range) EqualsTo(o Object) bool {
+	other, ok := o.(*Range)
+	if !ok {
+		return false
+	}
+	return r.Start == other.Start && r.Stop == other.Stop && r.Step == other.Step
+}
+
+// Len returns the number of elements in the range. The arithmetic is
+// done in uint64 so that ranges spanning all of int64 do not overflow.
+func (r *Range) Len() uint64 {
+	switch {
+	case r.Step > 0 && r.Start < r.Stop:
+		return (uint64(r.Stop)-uint64(r.Start)-1)/uint64(r.Step) + 1
+	case r.Step < 0 && r.Start > r.Stop:
+		return (uint64(r.Start)-uint64(r.Stop)-1)/(-uint64(r.Step)) + 1
+	default:
+		return 0
+	}
+}
+
+// At returns the i-th element of the range. i must be less than Len().
+func (r *Range) At(i uint64) int64 {
+	return int64(uint64(r.Start) + i*uint64(r.Step))
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -220,8 +523,50 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash remembers the order in which keys were inserted. Use Set and
+// Delete to modify it so that Keys stays in sync with Pairs.
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	Keys  []HashKey // in insertion order
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set adds or updates a pair. An updated key keeps its position.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if h.Pairs == nil {
+		h.Pairs = make(map[HashKey]HashPair)
+	}
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+// Delete removes a pair and reports whether it was present.
+func (h *Hash) Delete(key HashKey) bool {
+	if _, ok := h.Pairs[key]; !ok {
+		return false
+	}
+	delete(h.Pairs, key)
+	for i, k := range h.Keys {
+		if k == key {
+			h.Keys = append(h.Keys[:i:i], h.Keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// OrderedPairs returns the pairs in insertion order.
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, len(h.Keys))
+	for i, key := range h.Keys {
+		pairs[i] = h.Pairs[key]
+	}
+	return pairs
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -229,7 +574,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.OrderedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
 			pair.Key.Inspect(), pair.Value.Inspect()))
 	}
@@ -298,6 +643,39 @@ func (m *Macro) Inspect() string {
 	return out.String()
 }
 
+// Module is an imported file. If the file exports any names, only those
+// are visible from outside; otherwise all its top-level names are.
+type Module struct {
+	Name     string
+	Path     string
+	Env      *Environment
+	MacroEnv *Environment
+	Exports  map[string]bool // nil if the file exports nothing
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return fmt.Sprintf("<module %s>", m.Name) }
+
+// IsVisible reports whether name can be accessed from outside the module.
+func (m *Module) IsVisible(name string) bool {
+	return m.Exports == nil || m.Exports[name]
+}
+
+// Get returns a visible top-level binding of the module.
+func (m *Module) Get(name string) (Object, bool) {
+	if !m.IsVisible(name) {
+		return nil, false
+	}
+	return m.Env.Get(name)
+}
+
+// Modules holds the modules imported by an interpreter. The environments
+// of its program and of the modules share it.
+type Modules struct {
+	Loaded    map[string]*Module // by absolute path, so that each file is evaluated once
+	Importing []string           // the chain of files being imported, to detect cycles
+}
+
 type Equalable interface {
 	EqualsTo(Object) bool
 }
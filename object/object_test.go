@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -52,3 +56,127 @@ func TestBooleanHashKey(t *testing.T) {
 		t.Errorf("boolean with different content has same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	f1 := &Float{Value: 1.5}
+	f2 := &Float{Value: 1.5}
+	diff := &Float{Value: 2.5}
+	integral := &Float{Value: 2}
+
+	if f1.HashKey() != f2.HashKey() {
+		t.Errorf("floats with same content has different hash keys")
+	}
+
+	if f1.HashKey() == diff.HashKey() {
+		t.Errorf("floats with different content has same hash keys")
+	}
+
+	if integral.HashKey() != (&Integer{Value: 2}).HashKey() {
+		t.Errorf("integral float has different hash key from equal integer")
+	}
+
+	huge := &Float{Value: 1e20}
+	bigInt, _ := new(big.Int).SetString("100000000000000000000", 10)
+	if huge.HashKey() != (&BigInteger{Value: bigInt}).HashKey() {
+		t.Errorf("float beyond int64 has different hash key from equal integer")
+	}
+
+	// 2**53 + 1 is not a float64, so it equals no float
+	inexact := &Integer{Value: 1<<53 + 1}
+	if Equals(inexact, &Float{Value: 1 << 53}) || Equals(&Float{Value: 1 << 53}, inexact) {
+		t.Errorf("integer equals the float it rounds to")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{2, "2.0"},
+		{-0.5, "-0.5"},
+		{1e-9, "1e-09"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong inspect. want=%q, got=%q", tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestBigIntegerHashKey(t *testing.T) {
+	b1, _ := new(big.Int).SetString("18446744073709551616", 10)
+	b2, _ := new(big.Int).SetString("18446744073709551616", 10)
+	neg := new(big.Int).Neg(b1)
+
+	if NewInteger(b1).(Hashable).HashKey() != NewInteger(b2).(Hashable).HashKey() {
+		t.Errorf("big integers with same content has different hash keys")
+	}
+
+	if NewInteger(b1).(Hashable).HashKey() == NewInteger(neg).(Hashable).HashKey() {
+		t.Errorf("big integers with different content has same hash keys")
+	}
+}
+
+func TestNewInteger(t *testing.T) {
+	if _, ok := NewInteger(big.NewInt(42)).(*Integer); !ok {
+		t.Errorf("value in int64 range is not Integer")
+	}
+
+	b, _ := new(big.Int).SetString("18446744073709551616", 10)
+	if _, ok := NewInteger(b).(*BigInteger); !ok {
+		t.Errorf("value out of int64 range is not BigInteger")
+	}
+}
+
+func TestRangeLen(t *testing.T) {
+	tests := []struct {
+		r        *Range
+		expected uint64
+	}{
+		{&Range{Start: 0, Stop: 10, Step: 1}, 10},
+		{&Range{Start: 0, Stop: 10, Step: 3}, 4},
+		{&Range{Start: 10, Stop: 0, Step: -3}, 4},
+		{&Range{Start: 10, Stop: 0, Step: 1}, 0},
+		{&Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: 1}, math.MaxUint64},
+		{&Range{Start: math.MaxInt64, Stop: math.MinInt64, Step: math.MinInt64}, 2},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Len(); got != tt.expected {
+			t.Errorf("%s has wrong length. want=%d, got=%d", tt.r.Inspect(), tt.expected, got)
+		}
+	}
+
+	r := &Range{Start: math.MaxInt64, Stop: math.MinInt64, Step: math.MinInt64}
+	if r.At(1) != -1 {
+		t.Errorf("%s has wrong element at 1. want=-1, got=%d", r.Inspect(), r.At(1))
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	hash := &Hash{}
+	for _, s := range []string{"c", "a", "b"} {
+		key := &String{Value: s}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: key})
+	}
+	a := &String{Value: "a"}
+	hash.Set(a.HashKey(), HashPair{Key: a, Value: &Integer{Value: 1}})
+
+	if got := hash.Inspect(); got != "{c: c, a: 1, b: b}" {
+		t.Errorf("hash.Inspect() wrong. got=%q", got)
+	}
+
+	if !hash.Delete(a.HashKey()) {
+		t.Errorf("hash.Delete() reported a missing key")
+	}
+	if hash.Delete(a.HashKey()) {
+		t.Errorf("hash.Delete() reported a deleted key")
+	}
+	if got := hash.Inspect(); got != "{c: c, b: b}" {
+		t.Errorf("hash.Inspect() wrong after Delete. got=%q", got)
+	}
+}
@@ -0,0 +1,349 @@
+// Package optimizer rewrites a program into an equivalent one that does
+// less work at run time. It folds operations on constants, removes the
+// branches of if expressions whose condition is constant and inlines the
+// let bindings of constants.
+package optimizer
+
+import (
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/evaluator"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/token"
+)
+
+type optimizer struct {
+	// frozen holds the nodes quoted as data, which are left as they are
+	frozen map[ast.Node]bool
+	// inlinable holds the let statements that may bind constants: those
+	// run unconditionally at the top of a scope, for a name declared
+	// nowhere else and never assigned
+	inlinable map[*ast.LetStatement]bool
+	// refs holds the let statement each identifier refers to, if it is
+	// inlinable and the identifier comes after it in its scope
+	refs map[*ast.Identifier]*ast.LetStatement
+	// constants holds the constant values of the inlinable let statements
+	constants map[*ast.LetStatement]ast.Expression
+}
+
+// Optimize rewrites program in place and returns it. Macros have to be
+// expanded beforehand. Operations that fail, such as a division by zero,
+// are left to fail at run time.
+func Optimize(program *ast.Program) *ast.Program {
+	o := &optimizer{
+		frozen:    make(map[ast.Node]bool),
+		inlinable: make(map[*ast.LetStatement]bool),
+		refs:      make(map[*ast.Identifier]*ast.LetStatement),
+		constants: make(map[*ast.LetStatement]ast.Expression),
+	}
+	o.freezeQuotes(program)
+	o.findInlinable(program)
+	o.findRefs(program, nil)
+
+	return ast.Modify(program, o.modify).(*ast.Program)
+}
+
+func (o *optimizer) modify(node ast.Node) ast.Node {
+	if o.frozen[node] {
+		return node
+	}
+
+	switch node := node.(type) {
+	case *ast.Identifier:
+		return o.inline(node)
+
+	case *ast.LetStatement:
+		if o.inlinable[node] && isConstant(node.Value) {
+			o.constants[node] = node.Value
+		}
+
+	case *ast.PrefixExpression:
+		if isConstant(node.Right) {
+			return fold(node, evaluator.Prefix(node.Operator, toObject(node.Right)))
+		}
+
+	case *ast.InfixExpression:
+		return foldInfix(node)
+
+	case *ast.IfExpression:
+		// an if in a statement of its own is spliced into the block
+		// instead, where the branch may have any number of statements
+		if branch, ok := chooseBranch(node); ok && branch != nil && len(branch.Statements) == 1 {
+			if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+				return es.Expression
+			}
+		}
+
+	case *ast.BlockStatement:
+		node.Statements = spliceBranches(node.Statements)
+
+	case *ast.Program:
+		node.Statements = spliceBranches(node.Statements)
+	}
+	return node
+}
+
+// inline returns the value of the constant ident refers to, or ident if
+// it refers to none.
+func (o *optimizer) inline(ident *ast.Identifier) ast.Expression {
+	value, ok := o.constants[o.refs[ident]]
+	if !ok {
+		return ident
+	}
+	return literal(toObject(value), ident.Pos(), ident.End())
+}
+
+func foldInfix(node *ast.InfixExpression) ast.Expression {
+	if node.Operator == "&&" || node.Operator == "||" {
+		if !isConstant(node.Left) {
+			return node
+		}
+		truthy := evaluator.IsTruthy(toObject(node.Left))
+		if truthy == (node.Operator == "&&") {
+			return node.Right
+		}
+		return node.Left
+	}
+
+	if isConstant(node.Left) && isConstant(node.Right) {
+		return fold(node, evaluator.Infix(node.Operator, toObject(node.Left), toObject(node.Right)))
+	}
+	return node
+}
+
+// fold returns the literal of result, which node evaluates to, or node
+// if result has no literal.
+func fold(node ast.Expression, result object.Object) ast.Expression {
+	if lit := literal(result, node.Pos(), node.End()); lit != nil {
+		return lit
+	}
+	return node
+}
+
+// chooseBranch returns the branch the if expression runs, which is nil if
+// it runs none. It reports false if the condition is not constant.
+func chooseBranch(ie *ast.IfExpression) (*ast.BlockStatement, bool) {
+	if !isConstant(ie.Condition) {
+		return nil, false
+	}
+	if evaluator.IsTruthy(toObject(ie.Condition)) {
+		return ie.Consequence, true
+	}
+	return ie.Alternative, true
+}
+
+// spliceBranches replaces the if statements with a constant condition by
+// the statements of the branch they run. Blocks have no scope of their
+// own, so the statements run the same in the enclosing block. A branch
+// declaring variables is kept as it is, as a let may not be repeated in a
+// block but may be in a nested one. An if running no branch is kept at the
+// end of a block, whose value it is.
+func spliceBranches(stmts []ast.Statement) []ast.Statement {
+	var spliced []ast.Statement
+	for i, stmt := range stmts {
+		es, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			spliced = append(spliced, stmt)
+			continue
+		}
+		ie, ok := es.Expression.(*ast.IfExpression)
+		if !ok {
+			spliced = append(spliced, stmt)
+			continue
+		}
+		branch, ok := chooseBranch(ie)
+		switch {
+		case !ok || declares(branch):
+			spliced = append(spliced, stmt)
+		case branch != nil && len(branch.Statements) > 0:
+			spliced = append(spliced, branch.Statements...)
+		case i == len(stmts)-1:
+			spliced = append(spliced, stmt)
+		}
+	}
+	return spliced
+}
+
+// declares reports whether block has let statements of its own.
+func declares(block *ast.BlockStatement) bool {
+	if block == nil {
+		return false
+	}
+	for _, stmt := range block.Statements {
+		if _, ok := stmt.(*ast.LetStatement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// freezeQuotes adds the nodes quoted as data to o.frozen. The arguments of
+// unquote calls are evaluated, so they are not frozen.
+func (o *optimizer) freezeQuotes(program *ast.Program) {
+	ast.Inspect(program, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || call.Function.TokenLiteral() != "quote" || len(call.Arguments) != 1 {
+			return true
+		}
+		ast.Inspect(call.Arguments[0], func(node ast.Node) bool {
+			if unquote, ok := node.(*ast.CallExpression); ok && unquote.Function.TokenLiteral() == "unquote" {
+				o.frozen[node] = true
+				return false
+			}
+			o.frozen[node] = true
+			return true
+		})
+		return false
+	})
+}
+
+// findInlinable fills o.inlinable with the let statements at the top of
+// program and of function bodies, for the names declared once and never
+// assigned. A let nested in another statement may not run, and one in a
+// loop body runs once per iteration, so neither is inlined.
+func (o *optimizer) findInlinable(program *ast.Program) {
+	declared := make(map[string]int)
+	assigned := make(map[string]bool)
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			declared[node.Name.Value]++
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				declared[param.Value]++
+			}
+			if node.Rest != nil {
+				declared[node.Rest.Value]++
+			}
+		case *ast.ForStatement:
+			if node.Key != nil {
+				declared[node.Key.Value]++
+			}
+			declared[node.Value.Value]++
+		case *ast.TryExpression:
+			if node.Param != nil {
+				declared[node.Param.Value]++
+			}
+		case *ast.ImportStatement:
+			declared[node.Name()]++
+		case *ast.AssignExpression:
+			if ident, ok := node.Target.(*ast.Identifier); ok {
+				assigned[ident.Value] = true
+			}
+		}
+		return true
+	})
+
+	add := func(stmts []ast.Statement) {
+		for _, stmt := range stmts {
+			ls, ok := stmt.(*ast.LetStatement)
+			if ok && declared[ls.Name.Value] == 1 && !assigned[ls.Name.Value] {
+				o.inlinable[ls] = true
+			}
+		}
+	}
+	add(program.Statements)
+	ast.Inspect(program, func(node ast.Node) bool {
+		if fl, ok := node.(*ast.FunctionLiteral); ok {
+			add(fl.Body.Statements)
+		}
+		return true
+	})
+}
+
+// findRefs fills o.refs with the identifiers in node that refer to an
+// inlinable let statement of scopes, the program and the bodies of the
+// functions node is in, innermost last. An identifier refers to a let
+// statement that comes before it in its scope, or in an enclosing one.
+func (o *optimizer) findRefs(node ast.Node, scopes []map[string]*ast.LetStatement) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			o.findScope(node.Statements, scopes)
+			return false
+
+		case *ast.FunctionLiteral:
+			for _, def := range node.Defaults {
+				o.findRefs(def, scopes)
+			}
+			o.findScope(node.Body.Statements, scopes)
+			return false
+
+		case *ast.LetStatement:
+			if node.Value != nil {
+				o.findRefs(node.Value, scopes)
+			}
+			if o.inlinable[node] {
+				scopes[len(scopes)-1][node.Name.Value] = node
+			}
+			return false
+
+		case *ast.Identifier:
+			for i := len(scopes) - 1; i >= 0; i-- {
+				if ls, ok := scopes[i][node.Value]; ok {
+					o.refs[node] = ls
+					break
+				}
+			}
+		}
+		return true
+	})
+}
+
+// findScope runs findRefs on the statements of a new innermost scope.
+func (o *optimizer) findScope(stmts []ast.Statement, scopes []map[string]*ast.LetStatement) {
+	scopes = append(scopes[:len(scopes):len(scopes)], make(map[string]*ast.LetStatement))
+	for _, stmt := range stmts {
+		o.findRefs(stmt, scopes)
+	}
+}
+
+func isConstant(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	}
+	return false
+}
+
+func toObject(exp ast.Expression) object.Object {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		if exp.Big != nil {
+			return object.NewInteger(exp.Big)
+		}
+		return &object.Integer{Value: exp.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: exp.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: exp.Value}
+	case *ast.Boolean:
+		if exp.Value {
+			return evaluator.TRUE
+		}
+		return evaluator.FALSE
+	}
+	return nil
+}
+
+// literal returns the literal of obj spanning from pos to end, or nil if
+// obj is not an integer, a float, a string or a boolean.
+func literal(obj object.Object, pos, end token.Position) ast.Expression {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: obj.Inspect(), Pos: pos, End: end}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+	case *object.Float:
+		t := token.Token{Type: token.FLOAT, Literal: obj.Inspect(), Pos: pos, End: end}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Pos: pos, End: end}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false", Pos: pos, End: end}
+		if obj.Value {
+			t.Type, t.Literal = token.TRUE, "true"
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}
+	}
+	return nil
+}
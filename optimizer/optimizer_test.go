@@ -0,0 +1,104 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/evaluator"
+	"github.com/tatsuya4559/monkey/lexer"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+	return program
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"60 * 60 * 24", "86400"},
+		{`"a" + "b" + "c"`, "abc"},
+		{"-(1 + 2.5)", "-3.5"},
+		{"!(1 < 2) || 1 == 1", "true"},
+		{"x && 1 + 1", "(x && 2)"},
+		{"false && x", "false"},
+		{"true && x", "x"},
+		{"1 / 0", "(1 / 0)"},
+		{"9223372036854775807 + 1", "(9223372036854775807 + 1)"},
+		{"quote(1 + 2)", "quote((1 + 2))"},
+		{"quote(unquote(1 + 2) + 3)", "quote((unquote(3) + 3))"},
+		{"puts(if (1 > 2) { a } else { b })", "puts(b)"},
+		{"if (false) { a }; b", "b"},
+		{"if (true) { let a = 1; a }", "iftrue let a = 1;a"},
+		// the branch keeps its block, where y may be declared again
+		{"if (true) { let y = 2; } let y = 3; y", "iftrue let y = 2;let y = 3;y"},
+		{"if (false) { a }", "iffalse a"},
+		{"let a = 2; a * 3", "let a = 2;6"},
+		{"let a = 2; let b = a * 3; b + 1", "let a = 2;let b = 6;7"},
+		// a is used before it is bound
+		{"let f = fn() { a }; let a = 1; a", "let f = fn() a;let a = 1;1"},
+		// a is assigned or declared twice
+		{"let a = 1; a = 2; a", "let a = 1;a = 2a"},
+		{"let a = 1; fn(a) { a }; a", "let a = 1;fn(a) aa"},
+		// a may not be bound
+		{"if (x) { let a = 1; }; a", "ifx let a = 1;a"},
+		{"fn() { let a = 1; a }; fn() { a }", "fn() let a = 1;1fn() a"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(t, tt.input))
+		if program.String() != tt.expected {
+			t.Errorf("%s: wrong program. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestOptimizeExpandedMacro(t *testing.T) {
+	// the a expanded from the macro keeps the position of the macro,
+	// which comes before the let statement
+	input := "let m = macro() { quote(a) }; let a = 1; m()"
+
+	program := parse(t, input)
+	env := object.NewEnvironment()
+	evaluator.DefineMacros(program, env)
+	expanded := evaluator.ExpandMacros(program, env).(*ast.Program)
+
+	expected := "let a = 1;1"
+	if got := Optimize(expanded).String(); got != expected {
+		t.Errorf("wrong program. want=%q, got=%q", expected, got)
+	}
+}
+
+func TestOptimizeKeepsResult(t *testing.T) {
+	tests := []string{
+		"let day = 60 * 60 * 24; let f = fn(n) { n * day }; f(2)",
+		"let debug = false; let f = fn() { if (debug) { 1 } }; f()",
+		"let n = 2; if (n > 1) { let m = n * 3; }; m",
+		"let f = fn() { if (true) { let x = 1; } let x = 2; x }; f()",
+		"if (true) { let y = 2; } let y = 3; y",
+		"let xs = []; for x in [1, 2] { let y = x * 2; xs = push(xs, y); } xs",
+		"let s = 1; let f = fn() { s + 1 / 0 }; f()",
+		`let name = "x"; let g = fn() { "${name}" + "!" }; g()`,
+	}
+
+	for _, input := range tests {
+		want := evaluator.Eval(parse(t, input), object.NewEnvironment())
+		got := evaluator.Eval(Optimize(parse(t, input)), object.NewEnvironment())
+		if got.Inspect() != want.Inspect() {
+			t.Errorf("%s: wrong result. want=%s, got=%s", input, want.Inspect(), got.Inspect())
+		}
+		if err, ok := want.(*object.Error); ok && err.StackTrace() != got.(*object.Error).StackTrace() {
+			t.Errorf("%s: wrong stack trace. want=%q, got=%q", input, err.StackTrace(), got.(*object.Error).StackTrace())
+		}
+	}
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
 
 	"github.com/tatsuya4559/monkey/ast"
@@ -12,8 +13,15 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // =, +=
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
+	BITWISE_OR  // |
+	BITWISE_XOR // ^
+	BITWISE_AND // &
 	EQUALS      // ==
-	LESSGREATER // <, >
+	LESSGREATER // <, >, <=, >=
+	SHIFT       // <<, >>
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X, !X
@@ -22,17 +30,33 @@ const (
 )
 
 var precedence = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.ASTERISK: PRODUCT,
-	token.SLASH:    PRODUCT,
-	token.MOD:      PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.MOD_ASSIGN:      ASSIGN,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.BIT_OR:          BITWISE_OR,
+	token.BIT_XOR:         BITWISE_XOR,
+	token.BIT_AND:         BITWISE_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.LSHIFT:          SHIFT,
+	token.RSHIFT:          SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.ASTERISK:        PRODUCT,
+	token.SLASH:           PRODUCT,
+	token.MOD:             PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
 }
 
 type Parser struct {
@@ -43,6 +67,33 @@ type Parser struct {
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	errors     ErrorList
+	blockDepth int // number of enclosing block statements
+	loopDepth  int // number of enclosing loops in the current function
+}
+
+// Error is a parse error at a source position.
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// ErrorList is the list of errors found while parsing a program.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -51,9 +102,13 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_HEAD, p.parseInterpolatedString)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupExpression)
@@ -62,6 +117,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.THROW, p.parseThrowExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -73,8 +130,24 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.LSHIFT, p.parseInfixExpression)
+	p.registerInfix(token.RSHIFT, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MOD_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	// read token for setup
 	p.nextToken()
@@ -82,9 +155,52 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+func (p *Parser) errorf(pos token.Position, format string, a ...interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+func (p *Parser) addError(err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Pos: p.curToken.Pos, Msg: err.Error()}
+	}
+	p.errors = append(p.errors, e)
+}
+
+// synchronize skips tokens after a parse error until the beginning of
+// the next statement, so that parsing can go on and report more errors.
+// start is the position of the statement in which the error occurred.
+func (p *Parser) synchronize(start token.Position) {
+	if p.curTokenIs(token.RBRACE) && p.curToken.Pos == start {
+		// stray closing brace
+		p.nextToken()
+		return
+	}
+
+	for !p.curTokenIs(token.EOF) {
+		if p.curToken.Pos != start {
+			switch p.curToken.Type {
+			case token.LET, token.RETURN, token.WHILE, token.FOR, token.BREAK, token.CONTINUE,
+				token.IMPORT, token.EXPORT:
+				return
+			case token.RBRACE:
+				// leave it to close the enclosing block
+				if p.blockDepth > 0 {
+					return
+				}
+			}
+		}
+		if p.curTokenIs(token.SEMICOLON) {
+			p.nextToken()
+			return
+		}
+		p.nextToken()
+	}
+}
+
 func (p *Parser) newPeekError(t token.TokenType) error {
-	return fmt.Errorf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
+	return p.errorf(p.peekToken.Pos,
+		"expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) nextToken() {
@@ -98,19 +214,25 @@ func (p *Parser) nextToken() {
 	p.peekToken = tok
 }
 
-func (p *Parser) ParseProgram() (*ast.Program, error) {
+// ParseProgram parses the whole input. It does not stop at the first
+// error but returns every error found along with the statements that
+// could be parsed.
+func (p *Parser) ParseProgram() (*ast.Program, ErrorList) {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
+		start := p.curToken.Pos
 		stmt, err := p.parseStatement()
 		if err != nil {
-			return nil, err
+			p.addError(err)
+			p.synchronize(start)
+			continue
 		}
 		program.Statements = append(program.Statements, stmt)
 		p.nextToken()
 	}
-	return program, nil
+	return program, p.errors
 }
 
 func (p *Parser) parseStatement() (ast.Statement, error) {
@@ -121,6 +243,16 @@ func (p *Parser) parseStatement() (ast.Statement, error) {
 		return p.parseReturnStatement()
 	case token.WHILE:
 		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -147,6 +279,37 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 		return nil, err
 	}
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
+	if err := p.expectPeek(token.SEMICOLON); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseImportStatement() (*ast.ImportStatement, error) {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if p.blockDepth > 0 {
+		return nil, p.errorf(p.curToken.Pos, "import must be at top level")
+	}
+
+	if err := p.expectPeek(token.STRING); err != nil {
+		return nil, err
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.AS) {
+		p.nextToken()
+		if err := p.expectPeek(token.IDENT); err != nil {
+			return nil, err
+		}
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
 	if err := p.expectPeek(token.SEMICOLON); err != nil {
 		return nil, err
 	}
@@ -154,6 +317,26 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 	return stmt, nil
 }
 
+// parseExportStatement parses export let. It returns the let statement
+// marked as exported.
+func (p *Parser) parseExportStatement() (*ast.LetStatement, error) {
+	if p.blockDepth > 0 {
+		return nil, p.errorf(p.curToken.Pos, "export must be at top level")
+	}
+
+	if err := p.expectPeek(token.LET); err != nil {
+		return nil, err
+	}
+
+	stmt, err := p.parseLetStatement()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Exported = true
+
+	return stmt, nil
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -217,7 +400,7 @@ func (p *Parser) parseExpressionStatement() (*ast.ExpressionStatement, error) {
 }
 
 func (p *Parser) newNoPrefixParseFnError(t token.TokenType) error {
-	return fmt.Errorf("no prefix parse function for %s found", t)
+	return p.errorf(p.curToken.Pos, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parseExpression(precedence int) (ast.Expression, error) {
@@ -254,9 +437,28 @@ func (p *Parser) parseIdentifier() (ast.Expression, error) {
 func (p *Parser) parseIntegerLiteral() (ast.Expression, error) {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	// SetString checks the _ separators as the lexer does not
+	value, ok := new(big.Int).SetString(p.curToken.Literal, 0)
+	if !ok {
+		return nil, p.errorf(p.curToken.Pos,
+			"could not parse %q as integer", p.curToken.Literal)
+	}
+
+	if value.IsInt64() {
+		lit.Value = value.Int64()
+	} else {
+		lit.Big = value
+	}
+	return lit, nil
+}
+
+func (p *Parser) parseFloatLiteral() (ast.Expression, error) {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		return nil, fmt.Errorf("could not parse %q as integer", p.curToken.Literal)
+		return nil, p.errorf(p.curToken.Pos,
+			"could not parse %q as float", p.curToken.Literal)
 	}
 
 	lit.Value = value
@@ -267,6 +469,35 @@ func (p *Parser) parseStringLiteral() (ast.Expression, error) {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}, nil
 }
 
+func (p *Parser) parseInterpolatedString() (ast.Expression, error) {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+
+	for !p.curTokenIs(token.STRING_TAIL) {
+		p.nextToken() // skip ${
+		exp, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		str.Parts = append(str.Parts, exp)
+
+		if !p.peekTokenIs(token.STRING_MID) && !p.peekTokenIs(token.STRING_TAIL) {
+			return nil, p.errorf(p.peekToken.Pos,
+				"expected } after interpolated expression, got %s instead", p.peekToken.Type)
+		}
+		p.nextToken()
+		str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+	}
+
+	return str, nil
+}
+
+// parseIllegal reports an ILLEGAL token from the lexer, whose literal
+// describes the problem.
+func (p *Parser) parseIllegal() (ast.Expression, error) {
+	return nil, p.errorf(p.curToken.Pos, "%s", p.curToken.Literal)
+}
+
 func (p *Parser) parsePrefixExpression() (ast.Expression, error) {
 	expr := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -316,6 +547,30 @@ func (p *Parser) parseInfixExpression(left ast.Expression) (ast.Expression, erro
 	return expr, nil
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) (ast.Expression, error) {
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		return nil, p.errorf(target.Pos(), "invalid assignment target: %s", target)
+	}
+
+	expr := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+	var err error
+	// right associative: a = b = c is a = (b = c)
+	expr.Value, err = p.parseExpression(ASSIGN - 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return expr, nil
+}
+
 func (p *Parser) parseBoolean() (ast.Expression, error) {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}, nil
 }
@@ -375,16 +630,23 @@ func (p *Parser) parseBlockStatement() (*ast.BlockStatement, error) {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
 	p.nextToken() // skip LBRACE
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		start := p.curToken.Pos
 		stmt, err := p.parseStatement()
 		if err != nil {
-			return nil, err
+			p.addError(err)
+			p.synchronize(start)
+			continue
 		}
 		block.Statements = append(block.Statements, stmt)
 		p.nextToken()
 	}
+	block.RBrace = p.curToken
 
 	return block, nil
 }
@@ -392,12 +654,17 @@ func (p *Parser) parseBlockStatement() (*ast.BlockStatement, error) {
 func (p *Parser) parseFunctionLiteral() (ast.Expression, error) {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
+	// break and continue cannot reach a loop outside of the function
+	outerLoopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = outerLoopDepth }()
+
 	if err := p.expectPeek(token.LPAREN); err != nil {
 		return nil, err
 	}
 
 	var err error
-	lit.Parameters, err = p.parseFuntionParameters()
+	lit.Parameters, lit.Defaults, lit.Rest, err = p.parseFuntionParameters()
 	if err != nil {
 		return nil, err
 	}
@@ -411,34 +678,72 @@ func (p *Parser) parseFunctionLiteral() (ast.Expression, error) {
 		return nil, err
 	}
 
+	markTailCalls(lit)
 	return lit, nil
 }
 
-func (p *Parser) parseFuntionParameters() ([]*ast.Identifier, error) {
-	identifiers := []*ast.Identifier{}
+// parseFuntionParameters parses `a, b = default, ...rest)`.
+// defaults is nil unless some parameter has a default value.
+func (p *Parser) parseFuntionParameters() (
+	identifiers []*ast.Identifier,
+	defaults []ast.Expression,
+	rest *ast.Identifier,
+	err error,
+) {
+	identifiers = []*ast.Identifier{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers, nil
+		return identifiers, nil, nil, nil
 	}
 
-	p.nextToken()
+	for {
+		p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		if p.curTokenIs(token.ELLIPSIS) {
+			if err := p.expectPeek(token.IDENT); err != nil {
+				return nil, nil, nil, err
+			}
+			rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			// rest parameter must be the last one
+			break
+		}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
-		ident = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.curTokenIs(token.IDENT) {
+			return nil, nil, nil, p.errorf(p.curToken.Pos,
+				"expected parameter name, got %s instead", p.curToken.Type)
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def, err := p.parseExpression(LOWEST)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if defaults == nil {
+				defaults = make([]ast.Expression, len(identifiers)-1)
+			}
+			defaults = append(defaults, def)
+		} else if defaults != nil {
+			return nil, nil, nil, p.errorf(ident.Pos(),
+				"parameter %s without default follows parameter with default",
+				ident.Value)
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if err := p.expectPeek(token.RPAREN); err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	return identifiers, nil
+	return identifiers, defaults, rest, nil
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) (ast.Expression, error) {
@@ -448,6 +753,7 @@ func (p *Parser) parseCallExpression(function ast.Expression) (ast.Expression, e
 	if err != nil {
 		return nil, err
 	}
+	expr.RParen = p.curToken
 	return expr, nil
 }
 
@@ -458,6 +764,7 @@ func (p *Parser) parseArrayLiteral() (ast.Expression, error) {
 	if err != nil {
 		return nil, err
 	}
+	array.RBracket = p.curToken
 	return array, nil
 }
 
@@ -470,7 +777,7 @@ func (p *Parser) parseExpressionList(end token.TokenType) ([]ast.Expression, err
 	}
 
 	p.nextToken()
-	item, err := p.parseExpression(LOWEST)
+	item, err := p.parseListItem()
 	if err != nil {
 		return nil, err
 	}
@@ -479,7 +786,7 @@ func (p *Parser) parseExpressionList(end token.TokenType) ([]ast.Expression, err
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		item, err = p.parseExpression(LOWEST)
+		item, err = p.parseListItem()
 		if err != nil {
 			return nil, err
 		}
@@ -493,19 +800,98 @@ func (p *Parser) parseExpressionList(end token.TokenType) ([]ast.Expression, err
 	return list, nil
 }
 
-func (p *Parser) parseIndexExpression(left ast.Expression) (ast.Expression, error) {
-	expr := &ast.IndexExpression{Token: p.curToken, Left: left}
+// parseListItem parses an element of an argument list or an array literal,
+// which may be spread with `...`.
+func (p *Parser) parseListItem() (ast.Expression, error) {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
 
+	spread := &ast.SpreadExpression{Token: p.curToken}
 	p.nextToken()
+
+	var err error
+	spread.Value, err = p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+
+	return spread, nil
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) (ast.Expression, error) {
+	lbracket := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		var err error
+		index, err = p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(lbracket, left, index)
+	}
+
+	expr := &ast.IndexExpression{Token: lbracket, Left: left, Index: index}
+	if err := p.expectPeek(token.RBRACKET); err != nil {
+		return nil, err
+	}
+	expr.RBracket = p.curToken
+
+	return expr, nil
+}
+
+func (p *Parser) parseMemberExpression(object ast.Expression) (ast.Expression, error) {
+	expr := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if err := p.expectPeek(token.IDENT); err != nil {
+		return nil, err
+	}
+	expr.Member = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return expr, nil
+}
+
+// parseSliceExpression parses the rest of left[start:stop:step] from the
+// first colon.
+func (p *Parser) parseSliceExpression(
+	lbracket token.Token,
+	left, start ast.Expression,
+) (ast.Expression, error) {
+	expr := &ast.SliceExpression{Token: lbracket, Left: left, Start: start}
+
+	// parseBound parses an optional bound before a colon or ]
+	parseBound := func() (ast.Expression, error) {
+		if p.peekTokenIs(token.COLON) || p.peekTokenIs(token.RBRACKET) {
+			return nil, nil
+		}
+		p.nextToken()
+		return p.parseExpression(LOWEST)
+	}
+
+	p.nextToken() // first colon
 	var err error
-	expr.Index, err = p.parseExpression(LOWEST)
+	expr.Stop, err = parseBound()
 	if err != nil {
 		return nil, err
 	}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		expr.Step, err = parseBound()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if err := p.expectPeek(token.RBRACKET); err != nil {
 		return nil, err
 	}
+	expr.RBracket = p.curToken
 
 	return expr, nil
 }
@@ -532,6 +918,7 @@ func (p *Parser) parseHashLiteral() (ast.Expression, error) {
 		}
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) {
 			if err := p.expectPeek(token.COMMA); err != nil {
@@ -543,6 +930,7 @@ func (p *Parser) parseHashLiteral() (ast.Expression, error) {
 	if err := p.expectPeek(token.RBRACE); err != nil {
 		return nil, err
 	}
+	hash.RBrace = p.curToken
 
 	return hash, nil
 }
@@ -554,11 +942,19 @@ func (p *Parser) parseMacroLiteral() (ast.Expression, error) {
 		return nil, err
 	}
 
-	var err error
-	lit.Parameters, err = p.parseFuntionParameters()
+	var (
+		defaults []ast.Expression
+		rest     *ast.Identifier
+		err      error
+	)
+	lit.Parameters, defaults, rest, err = p.parseFuntionParameters()
 	if err != nil {
 		return nil, err
 	}
+	if defaults != nil || rest != nil {
+		return nil, p.errorf(lit.Token.Pos,
+			"default and rest parameters are not supported in macros")
+	}
 
 	if err := p.expectPeek(token.LBRACE); err != nil {
 		return nil, err
@@ -592,10 +988,151 @@ func (p *Parser) parseWhileStatement() (*ast.WhileStatement, error) {
 	if err := p.expectPeek(token.LBRACE); err != nil {
 		return nil, err
 	}
+	p.loopDepth++
+	stmt.Body, err = p.parseBlockStatement()
+	p.loopDepth--
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseForStatement() (*ast.ForStatement, error) {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if err := p.expectPeek(token.IDENT); err != nil {
+		return nil, err
+	}
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if err := p.expectPeek(token.IDENT); err != nil {
+			return nil, err
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if err := p.expectPeek(token.IN); err != nil {
+		return nil, err
+	}
+
+	p.nextToken() // skip IN
+	var err error
+	stmt.Iterable, err = p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expectPeek(token.LBRACE); err != nil {
+		return nil, err
+	}
+	p.loopDepth++
 	stmt.Body, err = p.parseBlockStatement()
+	p.loopDepth--
 	if err != nil {
 		return nil, err
 	}
 
 	return stmt, nil
 }
+
+func (p *Parser) parseBreakStatement() (*ast.BreakStatement, error) {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		return nil, p.errorf(p.curToken.Pos, "break outside loop")
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseContinueStatement() (*ast.ContinueStatement, error) {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		return nil, p.errorf(p.curToken.Pos, "continue outside loop")
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseTryExpression() (ast.Expression, error) {
+	expr := &ast.TryExpression{Token: p.curToken}
+
+	if err := p.expectPeek(token.LBRACE); err != nil {
+		return nil, err
+	}
+
+	var err error
+	expr.Block, err = p.parseBlockStatement()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if err := p.expectPeek(token.LPAREN); err != nil {
+			return nil, err
+		}
+		if err := p.expectPeek(token.IDENT); err != nil {
+			return nil, err
+		}
+		expr.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if err := p.expectPeek(token.RPAREN); err != nil {
+			return nil, err
+		}
+
+		if err := p.expectPeek(token.LBRACE); err != nil {
+			return nil, err
+		}
+		expr.Catch, err = p.parseBlockStatement()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if err := p.expectPeek(token.LBRACE); err != nil {
+			return nil, err
+		}
+		expr.Finally, err = p.parseBlockStatement()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if expr.Catch == nil && expr.Finally == nil {
+		return nil, p.errorf(p.peekToken.Pos,
+			"expected catch or finally, got %s instead", p.peekToken.Type)
+	}
+
+	return expr, nil
+}
+
+func (p *Parser) parseThrowExpression() (ast.Expression, error) {
+	expr := &ast.ThrowExpression{Token: p.curToken}
+
+	p.nextToken()
+
+	var err error
+	expr.Value, err = p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+
+	return expr, nil
+}
@@ -173,6 +173,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5; //comment", "!", 5},
 		{"-15;", "-", 15},
+		{"~15;", "~", 15},
 		{"!true;", "!", true},
 		{"!false", "!", false},
 	}
@@ -244,6 +245,11 @@ func TestParsingInfixExpression(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
@@ -285,6 +291,34 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!-a",
 			"(!(-a))",
 		},
+		{
+			"a <= b == b >= a",
+			"((a <= b) == (b >= a))",
+		},
+		{
+			"a || b && c || d",
+			"((a || (b && c)) || d)",
+		},
+		{
+			"x = a || b && c < d",
+			"x = (a || (b && (c < d)))",
+		},
+		{
+			"a | b ^ c & d == e",
+			"(a | (b ^ (c & (d == e))))",
+		},
+		{
+			"a << 1 + b < c >> d",
+			"((a << (1 + b)) < (c >> d))",
+		},
+		{
+			"a && b | c",
+			"(a && (b | c))",
+		},
+		{
+			"~a & -b",
+			"((~a) & (-b))",
+		},
 		{
 			"a + b + c",
 			"((a + b) + c)",
@@ -381,6 +415,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"-m.f(x)[0] * m.a.b",
+			"((-((m.f)(x)[0])) * ((m.a).b))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -1224,3 +1262,877 @@ while (i < 10) {
 		return
 	}
 }
+
+func TestNodePosition(t *testing.T) {
+	input := `let x = 1;
+add(x, [2, 3])[0] + {"a": 1}["a"];
+if x { 1 } else { 2 }`
+
+	tests := []struct {
+		expectedPos string
+		expectedEnd string
+	}{
+		{"1:1", "1:10"},
+		{"2:1", "2:34"},
+		{"3:1", "3:22"},
+	}
+
+	l := lexer.New(input)
+	p := New(l)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	if len(program.Statements) != len(tests) {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d",
+			len(tests), len(program.Statements))
+	}
+
+	for i, tt := range tests {
+		stmt := program.Statements[i]
+		if stmt.Pos().String() != tt.expectedPos {
+			t.Errorf("tests[%d] - pos wrong. expected=%s, got=%s",
+				i, tt.expectedPos, stmt.Pos())
+		}
+		if stmt.End().String() != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%s, got=%s",
+				i, tt.expectedEnd, stmt.End())
+		}
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x 5;", "main.mnk:1:7: expected next token to be =, got INT instead"},
+		{"let x = 5;\n\tlet = 1;", "main.mnk:2:6: expected next token to be IDENT, got = instead"},
+		{"1 + ;", "main.mnk:1:5: no prefix parse function for ; found"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewFile("main.mnk", tt.input)
+		p := New(l)
+		_, err := p.ParseProgram()
+		if err == nil {
+			t.Errorf("expected parse error for %q", tt.input)
+			continue
+		}
+		if err.Error() != tt.expectedError {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expectedError, err.Error())
+		}
+	}
+}
+
+func TestParseErrorRecovery(t *testing.T) {
+	input := `let x 5;
+let y = 10;
+let f = fn(a) {
+	let = a;
+	a * 2
+};
+return );
+1 + let z = 3;
+}
+y;`
+
+	expectedErrors := []string{
+		"1:7: expected next token to be =, got INT instead",
+		"4:6: expected next token to be IDENT, got = instead",
+		"7:8: no prefix parse function for ) found",
+		"8:5: no prefix parse function for let found",
+		"9:1: no prefix parse function for } found",
+	}
+	expectedStatements := []string{
+		"let y = 10;",
+		"let f = fn(a) (a * 2);",
+		"let z = 3;",
+		"y",
+	}
+
+	l := lexer.New(input)
+	p := New(l)
+	program, errs := p.ParseProgram()
+
+	if len(errs) != len(expectedErrors) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d (%v)",
+			len(expectedErrors), len(errs), errs)
+	}
+	for i, want := range expectedErrors {
+		if errs[i].Error() != want {
+			t.Errorf("errors[%d] wrong. want=%q, got=%q", i, want, errs[i].Error())
+		}
+	}
+
+	if len(program.Statements) != len(expectedStatements) {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d",
+			len(expectedStatements), len(program.Statements))
+	}
+	for i, want := range expectedStatements {
+		if program.Statements[i].String() != want {
+			t.Errorf("statements[%d] wrong. want=%q, got=%q",
+				i, want, program.Statements[i].String())
+		}
+	}
+}
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T",
+			program.Statements[0])
+	}
+
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Errorf("function literal name wrong. want 'myFunction', got=%q",
+			function.Name)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedParam   string
+		expectedCatch   bool
+		expectedFinally bool
+	}{
+		{`try { x } catch (e) { e }`, "e", true, false},
+		{`try { x } finally { y }`, "", false, true},
+		{`try { x } catch (err) { err } finally { y }`, "err", true, true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program, err := p.ParseProgram()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		expr, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T",
+				stmt.Expression)
+		}
+
+		if len(expr.Block.Statements) != 1 {
+			t.Errorf("try block is not 1 statements. got=%d",
+				len(expr.Block.Statements))
+		}
+
+		if (expr.Catch != nil) != tt.expectedCatch {
+			t.Errorf("catch clause wrong. want=%t, got=%+v", tt.expectedCatch, expr.Catch)
+		}
+		if tt.expectedCatch && !testIdentifer(t, expr.Param, tt.expectedParam) {
+			return
+		}
+
+		if (expr.Finally != nil) != tt.expectedFinally {
+			t.Errorf("finally clause wrong. want=%t, got=%+v", tt.expectedFinally, expr.Finally)
+		}
+	}
+}
+
+func TestTryWithoutHandler(t *testing.T) {
+	l := lexer.New(`try { x }`)
+	p := New(l)
+	_, err := p.ParseProgram()
+	if err == nil {
+		t.Fatalf("expected parse error")
+	}
+
+	expected := "1:10: expected catch or finally, got EOF instead"
+	if err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, err.Error())
+	}
+}
+
+func TestThrowExpression(t *testing.T) {
+	l := lexer.New(`throw x + 1;`)
+	p := New(l)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	expr, ok := stmt.Expression.(*ast.ThrowExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.ThrowExpression. got=%T",
+			stmt.Expression)
+	}
+
+	testInfixExpression(t, expr.Value, "x", "+", 1)
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9;", 1e-9},
+		{"2.5E+3;", 2500},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program, err := p.ParseProgram()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 10) {}", "fn(a, b = 10) "},
+		{"fn(a = 1, b = a * 2) {}", "fn(a = 1, b = (a * 2)) "},
+		{"fn(first, ...rest) {}", "fn(first, ...rest) "},
+		{"fn(...rest) {}", "fn(...rest) "},
+		{"fn(a, b = 2, ...rest) {}", "fn(a, b = 2, ...rest) "},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program, err := p.ParseProgram()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T",
+				stmt.Expression)
+		}
+
+		if function.String() != tt.expected {
+			t.Errorf("wrong function. want=%q, got=%q", tt.expected, function.String())
+		}
+	}
+}
+
+func TestInvalidFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"fn(a = 1, b) {}", "1:11: parameter b without default follows parameter with default"},
+		{"fn(...rest, a) {}", "1:11: expected next token to be ), got , instead"},
+		{"fn(1) {}", "1:4: expected parameter name, got INT instead"},
+		{"macro(...rest) {}", "1:1: default and rest parameters are not supported in macros"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		_, errs := p.ParseProgram()
+		if len(errs) == 0 {
+			t.Errorf("expected parse error for %q", tt.input)
+			continue
+		}
+		if errs[0].Error() != tt.expectedError {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expectedError, errs[0].Error())
+		}
+	}
+}
+
+func TestSpreadArguments(t *testing.T) {
+	input := "add(1, ...xs, ...[2, 3])"
+
+	l := lexer.New(input)
+	p := New(l)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if len(call.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(call.Arguments))
+	}
+
+	testLiteralExpression(t, call.Arguments[0], 1)
+
+	spread, ok := call.Arguments[1].(*ast.SpreadExpression)
+	if !ok {
+		t.Fatalf("call.Arguments[1] is not ast.SpreadExpression. got=%T",
+			call.Arguments[1])
+	}
+	testIdentifer(t, spread.Value, "xs")
+
+	if call.Arguments[2].String() != "...[2, 3]" {
+		t.Errorf("wrong argument. got=%q", call.Arguments[2].String())
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5;", "x = 5"},
+		{"x = y = 5;", "x = y = 5"},
+		{"x += 1 + 2 * 3;", "x += (1 + (2 * 3))"},
+		{"x -= 1", "x -= 1"},
+		{"x *= 2", "x *= 2"},
+		{"x /= 2", "x /= 2"},
+		{"x %= 2", "x %= 2"},
+		{"arr[i + 1] = x == y", "(arr[(i + 1)]) = (x == y)"},
+		{`h["k"] += 1`, "(h[k]) += 1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program, err := p.ParseProgram()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.AssignExpression); !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T",
+				stmt.Expression)
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"1 = 2", "1:1: invalid assignment target: 1"},
+		{"f() = 2", "1:1: invalid assignment target: f()"},
+		{"a + b = 2", "1:1: invalid assignment target: (a + b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		_, errs := p.ParseProgram()
+		if len(errs) == 0 {
+			t.Errorf("expected parse error for %q", tt.input)
+			continue
+		}
+		if errs[0].Error() != tt.expectedError {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expectedError, errs[0].Error())
+		}
+	}
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	input := `
+while (true) {
+	if (x) { break; }
+	continue
+}
+`
+
+	l := lexer.New(input)
+	p := New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.WhileStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body has not 2 statements. got=%d", len(stmt.Body.Statements))
+	}
+
+	exp := stmt.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if _, ok := exp.Consequence.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("consequence is not *ast.BreakStatement. got=%T",
+			exp.Consequence.Statements[0])
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("body.Statements[1] is not *ast.ContinueStatement. got=%T",
+			stmt.Body.Statements[1])
+	}
+}
+
+func TestBreakOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"break;", "1:1: break outside loop"},
+		{"if (true) { continue; }", "1:13: continue outside loop"},
+		{"while (true) { fn() { break; } }", "1:23: break outside loop"},
+		{"for x in xs { fn() { continue; } }", "1:22: continue outside loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		_, errs := p.ParseProgram()
+		if len(errs) == 0 {
+			t.Errorf("expected parse error for %q", tt.input)
+			continue
+		}
+		if errs[0].Error() != tt.expectedError {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expectedError, errs[0].Error())
+		}
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedPath  string
+		expectedAlias string
+		expectedStr   string
+	}{
+		{`import "lib/m.mnk";`, "lib/m.mnk", "", `import "lib/m.mnk";`},
+		{`import "m.mnk" as n;`, "m.mnk", "n", `import "m.mnk" as n;`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program, errs := p.ParseProgram()
+		if len(errs) != 0 {
+			t.Fatalf("parse error: %v", errs)
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("stmt is not *ast.ImportStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Path.Value != tt.expectedPath {
+			t.Errorf("stmt.Path wrong. want=%q, got=%q", tt.expectedPath, stmt.Path.Value)
+		}
+		if tt.expectedAlias == "" {
+			if stmt.Alias != nil {
+				t.Errorf("stmt.Alias is not nil. got=%s", stmt.Alias)
+			}
+		} else if !testIdentifer(t, stmt.Alias, tt.expectedAlias) {
+			return
+		}
+		if stmt.String() != tt.expectedStr {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.expectedStr, stmt.String())
+		}
+	}
+}
+
+func TestExportStatement(t *testing.T) {
+	l := lexer.New("export let x = 1;")
+	p := New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+	if !stmt.Exported {
+		t.Errorf("stmt.Exported is false")
+	}
+	if stmt.String() != "export let x = 1;" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestInvalidImportExport(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"import m;", "1:8: expected next token to be STRING, got IDENT instead"},
+		{`import "m" as 1;`, "1:15: expected next token to be IDENT, got INT instead"},
+		{`if (true) { import "m"; }`, "1:13: import must be at top level"},
+		{"fn() { export let x = 1; }", "1:8: export must be at top level"},
+		{"export x = 1;", "1:8: expected next token to be let, got IDENT instead"},
+		{"m.1", "1:3: expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		_, errs := p.ParseProgram()
+		if len(errs) == 0 {
+			t.Errorf("expected parse error for %q", tt.input)
+			continue
+		}
+		if errs[0].Error() != tt.expectedError {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expectedError, errs[0].Error())
+		}
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedKey   string
+		expectedValue string
+		expectedStr   string
+	}{
+		{"for x in xs { x }", "", "x", "for x in xs{x}"},
+		{"for i, x in [1, 2] { x }", "i", "x", "for i, x in [1, 2]{x}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program, errs := p.ParseProgram()
+		if len(errs) != 0 {
+			t.Fatalf("parse error: %v", errs)
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ForStatement. got=%T",
+				program.Statements[0])
+		}
+
+		if tt.expectedKey == "" {
+			if stmt.Key != nil {
+				t.Errorf("stmt.Key is not nil. got=%s", stmt.Key)
+			}
+		} else if !testIdentifer(t, stmt.Key, tt.expectedKey) {
+			return
+		}
+
+		if !testIdentifer(t, stmt.Value, tt.expectedValue) {
+			return
+		}
+
+		if stmt.String() != tt.expectedStr {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.expectedStr, stmt.String())
+		}
+	}
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0Xff", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0x_dead_beef", 0xdeadbeef},
+		{"0b1111_0000", 240},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program, errs := p.ParseProgram()
+		if len(errs) != 0 {
+			t.Fatalf("parse error: %v", errs)
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %d. got=%d", tt.expected, literal.Value)
+		}
+		if literal.TokenLiteral() != tt.input {
+			t.Errorf("literal.TokenLiteral not %s. got=%s", tt.input, literal.TokenLiteral())
+		}
+	}
+}
+
+func TestBigIntegerLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"99_999_999_999_999_999_999", "99999999999999999999"},
+		{"0x1_0000_0000_0000_0000", "18446744073709551616"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program, errs := p.ParseProgram()
+		if len(errs) != 0 {
+			t.Fatalf("parse error: %v", errs)
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Big == nil || literal.Big.String() != tt.expected {
+			t.Errorf("literal.Big not %s. got=%v", tt.expected, literal.Big)
+		}
+		if literal.TokenLiteral() != tt.input {
+			t.Errorf("literal.TokenLiteral not %s. got=%s", tt.input, literal.TokenLiteral())
+		}
+	}
+}
+
+func TestInvalidIntegerLiteral(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"0x", `1:1: could not parse "0x" as integer`},
+		{"0b102", `1:1: could not parse "0b102" as integer`},
+		{"1__0", `1:1: could not parse "1__0" as integer`},
+		{"1_", `1:1: could not parse "1_" as integer`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		_, errs := p.ParseProgram()
+		if len(errs) == 0 {
+			t.Errorf("expected parse error for %q", tt.input)
+			continue
+		}
+		if errs[0].Error() != tt.expectedError {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expectedError, errs[0].Error())
+		}
+	}
+}
+
+func TestInterpolatedString(t *testing.T) {
+	input := `"Hello ${name}, ${1 + 2}!"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 5 {
+		t.Fatalf("str.Parts has wrong length. want=5, got=%d", len(str.Parts))
+	}
+
+	for i, expected := range []string{"Hello ", ", ", "!"} {
+		lit, ok := str.Parts[i*2].(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("str.Parts[%d] not *ast.StringLiteral. got=%T", i*2, str.Parts[i*2])
+		}
+		if lit.Value != expected {
+			t.Errorf("str.Parts[%d] wrong. want=%q, got=%q", i*2, expected, lit.Value)
+		}
+	}
+
+	testIdentifer(t, str.Parts[1], "name")
+	testInfixExpression(t, str.Parts[3], 1, "+", 2)
+
+	if str.String() != "Hello ${name}, ${(1 + 2)}!" {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+
+	if str.End().Column != len(input)+1 {
+		t.Errorf("str.End() wrong. got=%s", str.End())
+	}
+}
+
+func TestInvalidString(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`let s = "abc`, "1:9: unterminated string"},
+		{`let s = "a\qb";`, `1:9: unknown escape sequence: \q`},
+		{`"x ${1 +}"`, "1:9: no prefix parse function for STRING_TAIL found"},
+		{`"x ${a b}"`, "1:8: expected } after interpolated expression, got IDENT instead"},
+		{"@", "1:1: unexpected character '@'"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		_, errs := p.ParseProgram()
+		if len(errs) == 0 {
+			t.Errorf("expected parse error for %q", tt.input)
+			continue
+		}
+		if errs[0].Error() != tt.expectedError {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expectedError, errs[0].Error())
+		}
+	}
+}
+
+func TestSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::-1]", "(a[::(-1)])"},
+		{"a[1:-1:2]", "(a[1:(-1):2])"},
+		{"a[x + 1:len(a)][0]", "((a[(x + 1):len(a)])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program, errs := p.ParseProgram()
+		if len(errs) != 0 {
+			t.Fatalf("parse error for %q: %v", tt.input, errs)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	l := lexer.New("a[1:2:3]")
+	p := New(l)
+	program, _ := p.ParseProgram()
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	slice, ok := stmt.Expression.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+	}
+	testIdentifer(t, slice.Left, "a")
+	testIntegerLiteral(t, slice.Start, 1)
+	testIntegerLiteral(t, slice.Stop, 2)
+	testIntegerLiteral(t, slice.Step, 3)
+	if slice.End().Column != 9 {
+		t.Errorf("slice.End() wrong. got=%s", slice.End())
+	}
+}
+
+func TestInvalidSliceExpression(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"a[1:2:3:4]", "1:8: expected next token to be ], got : instead"},
+		{"a[1 2]", "1:5: expected next token to be ], got INT instead"},
+		{"a[1:2] = 3", "1:1: invalid assignment target: (a[1:2])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		_, errs := p.ParseProgram()
+		if len(errs) == 0 {
+			t.Errorf("expected parse error for %q", tt.input)
+			continue
+		}
+		if errs[0].Error() != tt.expectedError {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expectedError, errs[0].Error())
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	input := `a();
+fn() {
+	b();
+	if (x) { return c(); d() } else { e() };
+	try { return f(); } catch (err) { g() };
+	fn() { h() };
+	i(j())
+};
+macro() { k() };
+fn() { if (x) { l() } else { m() } }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+
+	var tail []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok && call.Tail {
+			tail = append(tail, call.Function.String())
+		}
+		return true
+	})
+
+	expected := []string{"c", "h", "i", "l", "m"}
+	if fmt.Sprint(tail) != fmt.Sprint(expected) {
+		t.Errorf("wrong tail calls. want=%v, got=%v", expected, tail)
+	}
+}
@@ -0,0 +1,40 @@
+package parser
+
+import "github.com/tatsuya4559/monkey/ast"
+
+// markTailCalls sets Tail on the calls in tail position in the body of
+// fl: the last expression of the body, the values of return statements
+// and the same positions in the branches of an if expression there. Calls
+// in try expressions are never in tail position as catch and finally
+// come after. Nested function literals are marked when they are parsed.
+func markTailCalls(fl *ast.FunctionLiteral) {
+	ast.Inspect(fl.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral, *ast.TryExpression:
+			return false
+		case *ast.ReturnStatement:
+			markTailExpression(node.ReturnValue)
+		}
+		return true
+	})
+	markTailBlock(fl.Body)
+}
+
+func markTailBlock(block *ast.BlockStatement) {
+	if block == nil || len(block.Statements) == 0 {
+		return
+	}
+	if stmt, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement); ok {
+		markTailExpression(stmt.Expression)
+	}
+}
+
+func markTailExpression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.CallExpression:
+		expr.Tail = true
+	case *ast.IfExpression:
+		markTailBlock(expr.Consequence)
+		markTailBlock(expr.Alternative)
+	}
+}
@@ -0,0 +1,35 @@
+// Package prelude provides the Monkey code that is loaded before user code.
+package prelude
+
+import (
+	_ "embed"
+	"errors"
+
+	"github.com/tatsuya4559/monkey/evaluator"
+	"github.com/tatsuya4559/monkey/lexer"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/parser"
+)
+
+const filename = "stdlib.mnk"
+
+//go:embed stdlib.mnk
+var source string
+
+// Load evaluates the prelude into env and defines its macros in macroEnv.
+func Load(env, macroEnv *object.Environment) error {
+	l := lexer.NewFile(filename, source)
+	p := parser.New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		return errs
+	}
+
+	evaluator.DefineMacros(program, macroEnv)
+	expanded := evaluator.ExpandMacros(program, macroEnv)
+
+	if errObj, ok := evaluator.Engine(expanded, env).(*object.Error); ok {
+		return errors.New(errObj.Inspect())
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package prelude
+
+import (
+	"testing"
+
+	"github.com/tatsuya4559/monkey/evaluator"
+	"github.com/tatsuya4559/monkey/lexer"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/parser"
+)
+
+func TestLoad(t *testing.T) {
+	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+	if err := Load(env, macroEnv); err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+
+	if _, ok := macroEnv.Get("unless"); !ok {
+		t.Fatalf("macro unless is not defined")
+	}
+
+	program, errs := parser.New(lexer.New(`unless(1 > 2, "yes", "no")`)).ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parser errors: %v", errs)
+	}
+	expanded := evaluator.ExpandMacros(program, macroEnv)
+	evaluated := evaluator.Eval(expanded, env)
+
+	str, ok := evaluated.(*object.String)
+	if !ok || str.Value != "yes" {
+		t.Errorf("unless evaluated wrong. got=%s", evaluated.Inspect())
+	}
+}
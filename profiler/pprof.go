@@ -0,0 +1,228 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// The fields of the messages of profile.proto, the format of pprof.
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileTimeNanos         = 9
+	profileDurationNanos     = 10
+	profilePeriodType        = 11
+	profilePeriod            = 12
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// WritePprof writes the profile in the gzipped protocol buffer format of
+// pprof. Each stack of calls is a sample with the calls of its innermost
+// function and their usage excluding the calls they made. A function
+// calling itself directly appears once in a stack. A function is located
+// at the line of its definition.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	e := &encoder{strings: map[string]int64{"": 0}, table: []string{""}}
+
+	for _, vt := range [][2]string{
+		{"calls", "count"},
+		{"time", "nanoseconds"},
+		{"alloc_objects", "count"},
+		{"alloc_space", "bytes"},
+	} {
+		e.message(profileSampleType, func() {
+			e.int64(valueTypeType, e.string(vt[0]))
+			e.int64(valueTypeUnit, e.string(vt[1]))
+		})
+	}
+
+	// path holds the functions from main to the node walked, and stack
+	// the same innermost first, as pprof lists them
+	var path, stack []uint64
+	var walk func(n *node)
+	walk = func(n *node) {
+		path = append(path, n.fn.id)
+		stack = stack[:0]
+		for i := len(path) - 1; i >= 0; i-- {
+			stack = append(stack, path[i])
+		}
+		e.message(profileSample, func() {
+			e.packed(sampleLocationID, stack)
+			e.packed(sampleValue, []uint64{
+				uint64(n.calls), uint64(n.self.Time), n.self.Objects, n.self.Bytes,
+			})
+		})
+		for _, child := range n.sortedChildren() {
+			walk(child)
+		}
+		path = path[:len(path)-1]
+	}
+	walk(p.root)
+
+	for _, fn := range p.byID() {
+		e.message(profileLocation, func() {
+			e.uint64(locationID, fn.id)
+			e.message(locationLine, func() {
+				e.uint64(lineFunctionID, fn.id)
+				e.int64(lineLine, int64(fn.Pos.Line))
+			})
+		})
+	}
+	for _, fn := range p.byID() {
+		e.message(profileFunction, func() {
+			name := e.string(fn.symbol())
+			e.uint64(functionID, fn.id)
+			e.int64(functionName, name)
+			e.int64(functionSystemName, name)
+			e.int64(functionFilename, e.string(fn.Pos.Filename))
+			e.int64(functionStartLine, int64(fn.Pos.Line))
+		})
+	}
+
+	e.int64(profileTimeNanos, p.start.UnixNano())
+	e.int64(profileDurationNanos, int64(p.root.fn.Total.Time))
+	e.message(profilePeriodType, func() {
+		e.int64(valueTypeType, e.string("time"))
+		e.int64(valueTypeUnit, e.string("nanoseconds"))
+	})
+	e.int64(profilePeriod, 1)
+	e.int64(profileDefaultSampleType, e.string("time"))
+
+	// the strings are added while encoding the rest
+	for _, s := range e.table {
+		e.bytes(profileStringTable, []byte(s))
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(e.buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// symbol returns the name of fn in the pprof output. Functions are told
+// apart by their definitions, and pprof drops anything in <> or () from
+// names, so the name is followed by the position.
+func (fn *Function) symbol() string {
+	name := fn.Name
+	if name == "" {
+		name = "anonymous"
+	}
+	switch {
+	case fn.Builtin:
+		return "builtin." + name
+	case fn.Pos.IsValid():
+		return name + "@" + fn.Pos.String()
+	}
+	return name
+}
+
+// byID returns the functions in the order of their ids.
+func (p *Profiler) byID() []*Function {
+	fns := make([]*Function, len(p.functions))
+	for _, fn := range p.functions {
+		fns[fn.id-1] = fn
+	}
+	return fns
+}
+
+// sortedChildren returns the children of n in the order of the ids of
+// their functions, so that the output does not depend on map order.
+func (n *node) sortedChildren() []*node {
+	children := make([]*node, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].fn.id < children[j].fn.id
+	})
+	return children
+}
+
+// encoder encodes protocol buffer messages.
+type encoder struct {
+	buf     []byte
+	strings map[string]int64
+	table   []string
+}
+
+// string returns the index of s in the string table.
+func (e *encoder) string(s string) int64 {
+	i, ok := e.strings[s]
+	if !ok {
+		i = int64(len(e.table))
+		e.strings[s] = i
+		e.table = append(e.table, s)
+	}
+	return i
+}
+
+func (e *encoder) varint(x uint64) {
+	for x >= 0x80 {
+		e.buf = append(e.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	e.buf = append(e.buf, byte(x))
+}
+
+func (e *encoder) key(field int, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *encoder) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	e.key(field, 0)
+	e.varint(x)
+}
+
+func (e *encoder) int64(field int, x int64) {
+	e.uint64(field, uint64(x))
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	e.key(field, 2)
+	e.varint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) packed(field int, xs []uint64) {
+	var inner encoder
+	for _, x := range xs {
+		inner.varint(x)
+	}
+	e.bytes(field, inner.buf)
+}
+
+// message encodes the fields that encode adds as a message.
+func (e *encoder) message(field int, encode func()) {
+	outer := e.buf
+	e.buf = nil
+	encode()
+	inner := e.buf
+	e.buf = outer
+	e.bytes(field, inner)
+}
@@ -0,0 +1,274 @@
+// Package profiler records where a Monkey program spends its time. It
+// counts the calls of each function, by the position of its definition,
+// and of each builtin, and measures the wall time and the allocations of
+// the calls, including or excluding those of the functions they call.
+//
+// A function called in tail position takes the place of the function
+// calling it, as the engines reuse the frame. The call counts as made by
+// the caller of the latter, whose total leaves out its usage.
+//
+// The time and the memory the profiler uses itself are left out, but the
+// allocations it causes may still be counted in part, as the runtime
+// counts them a span of memory at a time.
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tatsuya4559/monkey/token"
+)
+
+// Usage is the time and the memory used by calls.
+type Usage struct {
+	Time    time.Duration
+	Objects uint64 // heap objects allocated
+	Bytes   uint64 // bytes allocated on the heap
+}
+
+func (u *Usage) add(v Usage) {
+	u.Time += v.Time
+	u.Objects += v.Objects
+	u.Bytes += v.Bytes
+}
+
+func (u Usage) sub(v Usage) Usage {
+	return Usage{Time: u.Time - v.Time, Objects: u.Objects - v.Objects, Bytes: u.Bytes - v.Bytes}
+}
+
+// Function is the profile of a function.
+type Function struct {
+	Name    string
+	Pos     token.Position // of the definition; invalid for builtins and main
+	Builtin bool
+	Calls   int
+	Total   Usage // including the calls it makes
+	Self    Usage // excluding the calls it makes
+
+	id     uint64 // in the pprof output
+	active int    // calls running, more than one if it recurses
+}
+
+// node is a stack of calls, with the usage of the innermost call. The
+// calls a function makes to itself directly are part of the same node, so
+// that deep recursion does not make a deep tree.
+type node struct {
+	fn       *Function
+	parent   *node
+	children map[*Function]*node
+	calls    int
+	self     Usage
+}
+
+// frame is a call running.
+type frame struct {
+	node     *node
+	start    Usage
+	children Usage // the usage of the calls it made
+}
+
+type key struct {
+	name    string
+	pos     token.Position
+	builtin bool
+}
+
+// Profiler records the calls of the functions of a program. The program
+// itself is the function main. Profiler implements evaluator.Profiler.
+type Profiler struct {
+	start    time.Time
+	overhead Usage // used by the profiler, which is left out
+	samples  []metrics.Sample
+	mem      runtime.MemStats // read instead if samples is nil
+
+	functions map[key]*Function
+	root      *node
+	stack     []frame
+}
+
+// New returns a profiler that starts recording main.
+func New() *Profiler {
+	p := &Profiler{start: time.Now(), functions: make(map[key]*Function)}
+	p.samples = allocSamples()
+	p.root = &node{fn: p.function(key{name: "main"}), children: make(map[*Function]*node)}
+	p.push(p.root, p.now(p.start))
+	return p
+}
+
+// allocSamples returns the metrics of the allocations, which unlike
+// runtime.ReadMemStats are read without stopping the world, or nil if the
+// runtime does not support them.
+func allocSamples() []metrics.Sample {
+	samples := []metrics.Sample{
+		{Name: "/gc/heap/allocs:objects"},
+		{Name: "/gc/heap/tiny/allocs:objects"},
+		{Name: "/gc/heap/allocs:bytes"},
+	}
+	metrics.Read(samples)
+	for _, s := range samples {
+		if s.Value.Kind() != metrics.KindUint64 {
+			return nil
+		}
+	}
+	return samples
+}
+
+// usage returns the usage since the profiler started, at t, including
+// that of the profiler.
+func (p *Profiler) usage(t time.Time) Usage {
+	if p.samples == nil {
+		runtime.ReadMemStats(&p.mem)
+		return Usage{Time: t.Sub(p.start), Objects: p.mem.Mallocs, Bytes: p.mem.TotalAlloc}
+	}
+	metrics.Read(p.samples)
+	return Usage{
+		Time:    t.Sub(p.start),
+		Objects: p.samples[0].Value.Uint64() + p.samples[1].Value.Uint64(),
+		Bytes:   p.samples[2].Value.Uint64(),
+	}
+}
+
+// now returns the usage since the profiler started, at t, excluding that
+// of the profiler.
+func (p *Profiler) now(t time.Time) Usage {
+	return p.usage(t).sub(p.overhead)
+}
+
+// leave adds the usage of the profiler since raw, its usage on entering
+// a hook, to the overhead.
+func (p *Profiler) leave(raw Usage) {
+	p.overhead.add(p.usage(time.Now()).sub(raw))
+}
+
+func (p *Profiler) function(k key) *Function {
+	fn, ok := p.functions[k]
+	if !ok {
+		fn = &Function{Name: k.name, Pos: k.pos, Builtin: k.builtin, id: uint64(len(p.functions) + 1)}
+		p.functions[k] = fn
+	}
+	return fn
+}
+
+func (p *Profiler) push(n *node, start Usage) {
+	n.calls++
+	n.fn.Calls++
+	n.fn.active++
+	p.stack = append(p.stack, frame{node: n, start: start})
+}
+
+// Enter records the start of a call of a function. Builtins are given an
+// invalid pos.
+func (p *Profiler) Enter(name string, pos token.Position) {
+	t := time.Now()
+	if len(p.stack) == 0 {
+		return
+	}
+	raw := p.usage(t)
+	start := raw.sub(p.overhead)
+
+	fn := p.function(key{name: name, pos: pos, builtin: !pos.IsValid()})
+	n := p.stack[len(p.stack)-1].node
+	if n.fn != fn {
+		child, ok := n.children[fn]
+		if !ok {
+			child = &node{fn: fn, parent: n, children: make(map[*Function]*node)}
+			n.children[fn] = child
+		}
+		n = child
+	}
+	p.push(n, start)
+
+	p.leave(raw)
+}
+
+// Exit records the end of the call entered last.
+func (p *Profiler) Exit() {
+	t := time.Now()
+	if len(p.stack) <= 1 {
+		return
+	}
+	raw := p.usage(t)
+	p.pop(raw.sub(p.overhead))
+	p.leave(raw)
+}
+
+func (p *Profiler) pop(end Usage) {
+	f := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	total := end.sub(f.start)
+	self := total.sub(f.children)
+	f.node.self.add(self)
+
+	fn := f.node.fn
+	fn.Self.add(self)
+	// a recursive call is part of the outermost one
+	fn.active--
+	if fn.active == 0 {
+		fn.Total.add(total)
+	}
+
+	if len(p.stack) > 0 {
+		p.stack[len(p.stack)-1].children.add(total)
+	}
+}
+
+// Stop ends the recording, along with the calls still running.
+func (p *Profiler) Stop() {
+	end := p.now(time.Now())
+	for len(p.stack) > 0 {
+		p.pop(end)
+	}
+}
+
+// Functions returns the profiles of the functions called, main first and
+// then by decreasing time spent in them.
+func (p *Profiler) Functions() []*Function {
+	fns := make([]*Function, 0, len(p.functions))
+	for _, fn := range p.functions {
+		fns = append(fns, fn)
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		if fns[i].id == 1 || fns[j].id == 1 {
+			return fns[i].id == 1
+		}
+		if fns[i].Self.Time != fns[j].Self.Time {
+			return fns[i].Self.Time > fns[j].Self.Time
+		}
+		return fns[i].id < fns[j].id
+	})
+	return fns
+}
+
+// WriteTable writes the profiles of the functions as a table.
+func (p *Profiler) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "calls\ttotal\tself\ttotal allocs\tself allocs\ttotal bytes\tself bytes\t  function")
+	for _, fn := range p.Functions() {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%d\t%d\t  %s\n",
+			fn.Calls, fn.Total.Time.Round(time.Microsecond), fn.Self.Time.Round(time.Microsecond),
+			fn.Total.Objects, fn.Self.Objects, fn.Total.Bytes, fn.Self.Bytes,
+			fn.describe())
+	}
+	return tw.Flush()
+}
+
+// describe returns the name of fn and where it is defined.
+func (fn *Function) describe() string {
+	name := fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	switch {
+	case fn.Builtin:
+		return name + " (builtin)"
+	case fn.Pos.IsValid():
+		return fmt.Sprintf("%s (%s)", name, fn.Pos)
+	}
+	return name
+}
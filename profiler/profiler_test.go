@@ -0,0 +1,178 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/evaluator"
+	"github.com/tatsuya4559/monkey/lexer"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/parser"
+	"github.com/tatsuya4559/monkey/vm"
+)
+
+const input = `let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } };
+let f = fn(x) { len([x]) };
+try { map([1, 2, 3], fn(x) { throw x }) } catch (e) { e };
+fib(10) + count(100) + f(1) + f(2)`
+
+var engines = []struct {
+	name string
+	run  func(ast.Node, *object.Environment) object.Object
+}{
+	{"eval", evaluator.Eval},
+	{"vm", vm.Run},
+}
+
+func profile(t *testing.T, run func(ast.Node, *object.Environment) object.Object) *Profiler {
+	t.Helper()
+	return profileInput(t, input, "57", run)
+}
+
+func profileInput(
+	t *testing.T,
+	input, expected string,
+	run func(ast.Node, *object.Environment) object.Object,
+) *Profiler {
+	t.Helper()
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+
+	prof := New()
+	evaluator.Profile = prof
+	defer func() { evaluator.Profile = nil }()
+
+	result := run(program, object.NewEnvironment())
+	if result.Inspect() != expected {
+		t.Fatalf("wrong result. got=%s", result.Inspect())
+	}
+	prof.Stop()
+	return prof
+}
+
+func TestProfile(t *testing.T) {
+	for _, engine := range engines {
+		prof := profile(t, engine.run)
+
+		calls := make(map[string]int)
+		var self Usage
+		for _, fn := range prof.Functions() {
+			calls[fn.describe()] = fn.Calls
+			self.add(fn.Self)
+
+			if fn.Self.Time > fn.Total.Time || fn.Self.Objects > fn.Total.Objects {
+				t.Errorf("%s: %s: self exceeds total. self=%+v, total=%+v",
+					engine.name, fn.describe(), fn.Self, fn.Total)
+			}
+		}
+
+		expected := map[string]int{
+			"main":               1,
+			"fib (1:11)":         177,
+			"count (2:13)":       101,
+			"f (3:9)":            2,
+			"len (builtin)":      2,
+			"map (builtin)":      1,
+			"<anonymous> (4:22)": 1,
+		}
+		if len(calls) != len(expected) {
+			t.Errorf("%s: wrong functions. want=%v, got=%v", engine.name, expected, calls)
+		}
+		for name, n := range expected {
+			if calls[name] != n {
+				t.Errorf("%s: wrong number of calls of %s. want=%d, got=%d",
+					engine.name, name, n, calls[name])
+			}
+		}
+
+		// the calls of main include all others, even recursive ones
+		main := prof.Functions()[0]
+		if main.Total != self {
+			t.Errorf("%s: self usages do not add up to main. want=%+v, got=%+v",
+				engine.name, main.Total, self)
+		}
+	}
+}
+
+func TestRecursionIsOneNode(t *testing.T) {
+	prof := profile(t, evaluator.Eval)
+
+	for fn, n := range prof.root.children {
+		if fn.Name != "count" {
+			continue
+		}
+		if n.calls != 101 {
+			t.Errorf("wrong number of calls. want=101, got=%d", n.calls)
+		}
+		if len(n.children) != 0 {
+			t.Errorf("recursive calls are not merged. got=%d children", len(n.children))
+		}
+		return
+	}
+	t.Fatalf("count is not called from main")
+}
+
+func TestTailCalls(t *testing.T) {
+	input := `let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+even(10)`
+
+	for _, engine := range engines {
+		prof := profileInput(t, input, "true", engine.run)
+
+		// the functions called in tail position are called from main
+		// in place of their callers, which leave out their usage
+		calls := make(map[string]int)
+		for fn, n := range prof.root.children {
+			calls[fn.describe()] = n.calls
+			if len(n.children) != 0 {
+				t.Errorf("%s: %s has callees. got=%d", engine.name, fn.describe(), len(n.children))
+			}
+			if fn.Total != fn.Self {
+				t.Errorf("%s: %s: total differs from self. self=%+v, total=%+v",
+					engine.name, fn.describe(), fn.Self, fn.Total)
+			}
+		}
+		expected := map[string]int{"even (1:12)": 6, "odd (2:11)": 5}
+		if len(calls) != len(expected) {
+			t.Errorf("%s: wrong functions. want=%v, got=%v", engine.name, expected, calls)
+		}
+		for name, n := range expected {
+			if calls[name] != n {
+				t.Errorf("%s: wrong number of calls of %s. want=%d, got=%d",
+					engine.name, name, n, calls[name])
+			}
+		}
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	prof := profile(t, evaluator.Eval)
+
+	var buf bytes.Buffer
+	if err := prof.WritePprof(&buf); err != nil {
+		t.Fatalf("WritePprof failed: %v", err)
+	}
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("output is not gzipped: %v", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("output is not gzipped: %v", err)
+	}
+
+	for _, s := range []string{"time", "nanoseconds", "main", "fib@1:11", "anonymous@4:22", "builtin.len"} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("string table has no %q", s)
+		}
+	}
+}
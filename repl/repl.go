@@ -20,11 +20,18 @@ const MONKEY_FACE = `
     (_(∪)_)∪
 `
 
+// Start runs the REPL. The prelude, if set in the evaluator, is loaded
+// before the first line.
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
 	macroEnv := object.NewEnvironment()
 
+	if err := evaluator.LoadPrelude(env, macroEnv); err != nil {
+		fmt.Fprintln(out, err.Inspect())
+		return
+	}
+
 	for {
 		fmt.Print(PROMPT)
 		scanned := scanner.Scan()
@@ -33,29 +40,57 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		evalLine(out, line, env, macroEnv)
+	}
+}
 
-		program, err := p.ParseProgram()
-		if err != nil {
-			printParseError(out, err)
-			continue
+// evalLine evaluates a line of input. A panic inside the interpreter is
+// reported as an error so that the session survives.
+func evalLine(out io.Writer, line string, env, macroEnv *object.Environment) {
+	defer func() {
+		if r := recover(); r != nil {
+			errObj := &object.Error{
+				Kind:    object.RUNTIME_ERROR,
+				Message: fmt.Sprintf("internal error: %v", r),
+			}
+			io.WriteString(out, errObj.Inspect())
+			io.WriteString(out, "\n")
 		}
+	}()
 
-		evaluator.DefineMacros(program, macroEnv)
-		expanded := evaluator.ExpandMacros(program, macroEnv)
+	l := lexer.New(line)
+	p := parser.New(l)
 
-		evaluated := evaluator.Eval(expanded, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
-		}
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		printParseErrors(out, errs)
+		return
+	}
+
+	if errObj := evaluator.ImportMacros(program, env, macroEnv); errObj != nil {
+		io.WriteString(out, errObj.Inspect())
+		io.WriteString(out, "\n")
+		io.WriteString(out, errObj.StackTrace())
+		return
+	}
+	evaluator.DefineMacros(program, macroEnv)
+	expanded := evaluator.ExpandMacros(program, macroEnv)
+
+	evaluated := evaluator.Engine(expanded, env)
+	if evaluated != nil {
+		io.WriteString(out, evaluated.Inspect())
+		io.WriteString(out, "\n")
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		io.WriteString(out, errObj.StackTrace())
 	}
 }
 
-func printParseError(out io.Writer, err error) {
+func printParseErrors(out io.Writer, errs parser.ErrorList) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
-	io.WriteString(out, " parser error:\n")
-	io.WriteString(out, "\t"+err.Error()+"\n")
+	io.WriteString(out, " parser errors:\n")
+	for _, err := range errs {
+		io.WriteString(out, "\t"+err.Error()+"\n")
+	}
 }
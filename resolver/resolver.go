@@ -0,0 +1,304 @@
+// Package resolver resolves the variables of a program before it runs.
+// It finds where each identifier is stored so that the evaluator does not
+// look local variables up by name, and reports the names that are never
+// declared and the names declared twice in a block.
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/token"
+)
+
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// ErrorList is the list of errors found while resolving a program.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// scope is a function body, a for-in iteration or a catch clause, which
+// have environments of their own at run time. Other blocks share the
+// scope that encloses them.
+type scope struct {
+	slots    map[string]int
+	declared map[string]bool // the names declared so far
+	function bool            // whether the scope is a function body
+}
+
+type resolver struct {
+	scopes  []*scope
+	globals map[string]bool
+	defined func(name string) bool
+	errors  ErrorList
+}
+
+// Resolve sets the bindings of the identifiers in program. Variables
+// declared at the top level are global and keep no binding. defined
+// reports whether a global name is bound outside of program, such as a
+// builtin or a variable of an earlier program run in the same
+// environment.
+//
+// A local variable is bound from its declaration on. Before it, its name
+// refers to the variable outside of its scope, except in the functions
+// defined in the scope, which may be called once the variable is bound.
+func Resolve(program *ast.Program, defined func(name string) bool) ErrorList {
+	r := &resolver{globals: make(map[string]bool), defined: defined}
+
+	for _, name := range r.declaredLets(program) {
+		r.globals[name.Value] = true
+	}
+	for _, stmt := range program.Statements {
+		if is, ok := stmt.(*ast.ImportStatement); ok {
+			r.globals[is.Name()] = true
+		}
+	}
+
+	ast.Inspect(program, r.visit)
+	return r.errors
+}
+
+func (r *resolver) errorf(pos token.Position, format string, a ...interface{}) {
+	r.errors = append(r.errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
+}
+
+func (r *resolver) resolve(node ast.Node) {
+	if node != nil {
+		ast.Inspect(node, r.visit)
+	}
+}
+
+func (r *resolver) resolveExpression(exp ast.Expression) {
+	if exp != nil {
+		ast.Inspect(exp, r.visit)
+	}
+}
+
+func (r *resolver) visit(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		r.use(node, "identifier not found: %s")
+		return false
+
+	case *ast.LetStatement:
+		// the value is evaluated before the variable is bound
+		r.resolveExpression(node.Value)
+		if len(r.scopes) > 0 {
+			r.declare(node.Name)
+		}
+		return false
+
+	case *ast.AssignExpression:
+		if ident, ok := node.Target.(*ast.Identifier); ok {
+			r.use(ident, "assignment to undeclared variable: %s")
+		} else {
+			r.resolveExpression(node.Target)
+		}
+		r.resolveExpression(node.Value)
+		return false
+
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			r.resolveQuote(node)
+			return false
+		}
+
+	case *ast.FunctionLiteral:
+		r.resolveFunction(node)
+		return false
+
+	case *ast.ForStatement:
+		r.resolveExpression(node.Iterable)
+
+		r.pushScope(false)
+		if node.Key != nil {
+			r.declare(node.Key)
+		}
+		r.declare(node.Value)
+		r.declareLocals(node.Body)
+		r.resolve(node.Body)
+		r.popScope()
+		return false
+
+	case *ast.TryExpression:
+		r.resolve(node.Block)
+		if node.Catch != nil {
+			r.pushScope(false)
+			r.declare(node.Param)
+			r.declareLocals(node.Catch)
+			r.resolve(node.Catch)
+			r.popScope()
+		}
+		if node.Finally != nil {
+			r.resolve(node.Finally)
+		}
+		return false
+
+	case *ast.ImportStatement, *ast.MacroLiteral:
+		return false
+	}
+	return true
+}
+
+// use sets the binding of ident, which refers to a variable, or reports
+// it with format if the variable is not declared.
+func (r *resolver) use(ident *ast.Identifier, format string) {
+	binding, ok := r.lookup(ident.Value)
+	if !ok {
+		r.errorf(ident.Pos(), format, ident.Value)
+		return
+	}
+	ident.Binding = binding
+}
+
+// lookup returns the binding of name, with the depth counted from the
+// innermost scope, or nil if name is global. A scope outside of a function
+// binds all its variables by the time the function is called, and others
+// only those declared so far. It reports false if name is declared
+// nowhere.
+func (r *resolver) lookup(name string) (*ast.Binding, bool) {
+	called := false
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		s := r.scopes[i]
+		if slot, ok := s.slots[name]; ok && (called || s.declared[name]) {
+			return &ast.Binding{Depth: len(r.scopes) - 1 - i, Slot: slot}, true
+		}
+		called = called || s.function
+	}
+	return nil, r.globals[name] || r.defined(name)
+}
+
+// resolveQuote resolves the arguments of the unquote calls in a quote
+// call, which are evaluated where the quote is.
+func (r *resolver) resolveQuote(call *ast.CallExpression) {
+	if len(call.Arguments) != 1 {
+		return
+	}
+	ast.Inspect(call.Arguments[0], func(node ast.Node) bool {
+		unquote, ok := node.(*ast.CallExpression)
+		if !ok || unquote.Function.TokenLiteral() != "unquote" {
+			return true
+		}
+		if len(unquote.Arguments) == 1 {
+			r.resolveExpression(unquote.Arguments[0])
+		}
+		return false
+	})
+}
+
+func (r *resolver) resolveFunction(fl *ast.FunctionLiteral) {
+	r.pushScope(true)
+	defer r.popScope()
+
+	for _, param := range fl.Parameters {
+		r.declare(param)
+	}
+	if fl.Rest != nil {
+		r.declare(fl.Rest)
+	}
+	r.declareLocals(fl.Body)
+
+	// default values are evaluated in the environment of the call
+	for _, def := range fl.Defaults {
+		r.resolveExpression(def)
+	}
+	r.resolve(fl.Body)
+}
+
+func (r *resolver) pushScope(function bool) {
+	r.scopes = append(r.scopes, &scope{
+		slots:    make(map[string]int),
+		declared: make(map[string]bool),
+		function: function,
+	})
+}
+
+func (r *resolver) popScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// declare assigns a slot of the innermost scope to ident unless its name
+// has one, and sets the binding of ident to it.
+func (r *resolver) declare(ident *ast.Identifier) {
+	s := r.scopes[len(r.scopes)-1]
+	slot, ok := s.slots[ident.Value]
+	if !ok {
+		slot = len(s.slots)
+		s.slots[ident.Value] = slot
+	}
+	s.declared[ident.Value] = true
+	ident.Binding = &ast.Binding{Slot: slot}
+}
+
+// declareLocals assigns slots of the innermost scope to the variables of
+// the let statements in body, which the functions defined in the scope
+// may refer to before they are declared.
+func (r *resolver) declareLocals(body *ast.BlockStatement) {
+	s := r.scopes[len(r.scopes)-1]
+	for _, name := range r.declaredLets(body) {
+		if _, ok := s.slots[name.Value]; !ok {
+			s.slots[name.Value] = len(s.slots)
+		}
+	}
+}
+
+// declaredLets returns the names of the let statements in the scope of
+// root, reporting those declared twice in the same block. A name declared
+// again in another block, such as a branch of an if or the body of a
+// while, is the same variable.
+func (r *resolver) declaredLets(root ast.Node) []*ast.Identifier {
+	var names []*ast.Identifier
+
+	var collect func(block ast.Node)
+	collect = func(block ast.Node) {
+		seen := make(map[string]bool)
+		var visit func(ast.Node) bool
+		visit = func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.LetStatement:
+				if seen[node.Name.Value] {
+					r.errorf(node.Name.Pos(), "duplicate declaration of %s", node.Name.Value)
+				}
+				seen[node.Name.Value] = true
+				names = append(names, node.Name)
+			case *ast.BlockStatement:
+				if node != block {
+					collect(node)
+					return false
+				}
+			case *ast.FunctionLiteral, *ast.MacroLiteral:
+				return false
+			case *ast.ForStatement:
+				ast.Inspect(node.Iterable, visit)
+				return false
+			case *ast.TryExpression:
+				ast.Inspect(node.Block, visit)
+				if node.Finally != nil {
+					ast.Inspect(node.Finally, visit)
+				}
+				return false
+			}
+			return true
+		}
+		ast.Inspect(block, visit)
+	}
+
+	collect(root)
+	return names
+}
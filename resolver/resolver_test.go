@@ -0,0 +1,150 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/lexer"
+	"github.com/tatsuya4559/monkey/parser"
+)
+
+func testResolve(t *testing.T, input string) (*ast.Program, ErrorList) {
+	t.Helper()
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+
+	builtins := map[string]bool{"len": true}
+	return program, Resolve(program, func(name string) bool { return builtins[name] })
+}
+
+// identifiers returns the identifiers used in node by name, last wins.
+func identifiers(node ast.Node) map[string]*ast.Identifier {
+	idents := make(map[string]*ast.Identifier)
+	ast.Inspect(node, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			idents[ident.Value] = ident
+		}
+		return true
+	})
+	return idents
+}
+
+func TestResolveBindings(t *testing.T) {
+	input := `let g = 1;
+let f = fn(a, b) {
+	let c = a;
+	for x in [b] {
+		fn() { len(c) + x + g };
+	}
+};`
+	program, errs := testResolve(t, input)
+	if len(errs) != 0 {
+		t.Fatalf("resolve error: %v", errs)
+	}
+
+	tests := []struct {
+		name     string
+		expected *ast.Binding
+	}{
+		{"g", nil},
+		{"len", nil},
+		{"a", &ast.Binding{Depth: 0, Slot: 0}},
+		{"b", &ast.Binding{Depth: 0, Slot: 1}},
+		{"c", &ast.Binding{Depth: 2, Slot: 2}},
+		{"x", &ast.Binding{Depth: 1, Slot: 0}},
+	}
+
+	idents := identifiers(program)
+	for _, tt := range tests {
+		binding := idents[tt.name].Binding
+		if tt.expected == nil {
+			if binding != nil {
+				t.Errorf("%s: want no binding, got=%+v", tt.name, binding)
+			}
+			continue
+		}
+		if binding == nil || binding.Depth != tt.expected.Depth || binding.Slot != tt.expected.Slot {
+			t.Errorf("%s: wrong binding. want=%+v, got=%+v", tt.name, tt.expected, binding)
+		}
+	}
+}
+
+func TestResolveBeforeDeclaration(t *testing.T) {
+	// y is read before the local x is declared, so it refers to the x of
+	// the enclosing function, while g is called once h is declared
+	input := `let x = 1;
+fn() {
+	let x = 2;
+	fn() { let y = x; let x = 3; };
+	let g = fn() { h() };
+	let h = fn() { 1 };
+};`
+	program, errs := testResolve(t, input)
+	if len(errs) != 0 {
+		t.Fatalf("resolve error: %v", errs)
+	}
+
+	var read, called *ast.Identifier
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			if node.Name.Value == "y" {
+				read = node.Value.(*ast.Identifier)
+			}
+		case *ast.CallExpression:
+			called = node.Function.(*ast.Identifier)
+		}
+		return true
+	})
+
+	if b := read.Binding; b == nil || b.Depth != 1 || b.Slot != 0 {
+		t.Errorf("x: wrong binding. want=&{Depth:1 Slot:0}, got=%+v", b)
+	}
+	if b := called.Binding; b == nil || b.Depth != 1 || b.Slot != 2 {
+		t.Errorf("h: wrong binding. want=&{Depth:1 Slot:2}, got=%+v", b)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"foo", []string{"1:1: identifier not found: foo"}},
+		{"fn() { foo = 1 }", []string{"1:8: assignment to undeclared variable: foo"}},
+		{"let a = 1; let a = 2;", []string{"1:16: duplicate declaration of a"}},
+		{"fn() { if (true) { let a = 1; let a = 2; } }", []string{"1:35: duplicate declaration of a"}},
+		{"for x in [] { let y = 1; let y = x; }", []string{"1:30: duplicate declaration of y"}},
+		{"fn() { foo } ; bar", []string{
+			"1:8: identifier not found: foo",
+			"1:16: identifier not found: bar",
+		}},
+		// declared later, in another scope or outside of the program
+		{"let f = fn() { g() }; let g = fn() { len([]) };", nil},
+		{"fn(a) { let a = 1; }; for x in [] { let x = 2; }", nil},
+		// the same variable declared again in another block
+		{"fn(a) { if (a) { let x = 1; x } else { let x = 2; x } }", nil},
+		{"let s = 0; while (s < 3) { let s = s + 1; }", nil},
+		{`import "lib/math.mnk"; import "b.mnk" as c; math; c`, nil},
+		{"quote(unquote(1) + foo)", nil},
+		{"try { 1 } catch (e) { e } ; try { 2 } catch (e) { e }", nil},
+	}
+
+	for _, tt := range tests {
+		_, errs := testResolve(t, tt.input)
+		if len(errs) != len(tt.expected) {
+			t.Errorf("%s: wrong number of errors. want=%d, got=%d (%v)",
+				tt.input, len(tt.expected), len(errs), errs)
+			continue
+		}
+		for i, err := range errs {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("%s: wrong error. want=%q, got=%q", tt.input, tt.expected[i], err.Error())
+			}
+		}
+	}
+}
@@ -1,40 +1,96 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // position of the first character
+	End     Position // position immediately after the last character
+}
+
+// Position describes a location in the source.
+// Line and Column are 1-based and Column counts runes.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+// IsValid reports whether the position has been set.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns "file:line:column", "line:column" if the file name is
+// empty, or "-" if the position is invalid.
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
 }
 
 const (
-	ILLEGAL = "ILLEGAL"
+	ILLEGAL = "ILLEGAL" // Literal describes the problem
 	EOF     = "EOF"
 	COMMENT = "COMMENT"
 
 	// identifier and literal
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
+	// parts of an interpolated string "head${x}mid${y}tail"
+	STRING_HEAD = "STRING_HEAD"
+	STRING_MID  = "STRING_MID"
+	STRING_TAIL = "STRING_TAIL"
+
 	// operator
-	ASSIGN   = "="
-	PLUS     = "+"
-	MINUS    = "-"
-	ASTERISK = "*"
-	SLASH    = "/"
-	MOD      = "%"
-	BANG     = "!"
+	ASSIGN          = "="
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	MOD_ASSIGN      = "%="
+	PLUS            = "+"
+	MINUS           = "-"
+	ASTERISK        = "*"
+	SLASH           = "/"
+	MOD             = "%"
+	BANG            = "!"
+	BIT_AND         = "&"
+	BIT_OR          = "|"
+	BIT_XOR         = "^"
+	BIT_NOT         = "~"
+	LSHIFT          = "<<"
+	RSHIFT          = ">>"
 
 	EQ     = "=="
 	NOT_EQ = "!="
 	LT     = "<"
 	GT     = ">"
+	LT_EQ  = "<="
+	GT_EQ  = ">="
+	AND    = "&&"
+	OR     = "||"
 
 	// delimiter
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ELLIPSIS  = "..."
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -53,18 +109,40 @@ const (
 	RETURN   = "return"
 	MACRO    = "macro"
 	WHILE    = "while"
+	BREAK    = "break"
+	FOR      = "for"
+	IN       = "in"
+	CONTINUE = "continue"
+	TRY      = "try"
+	CATCH    = "catch"
+	FINALLY  = "finally"
+	THROW    = "throw"
+	IMPORT   = "import"
+	EXPORT   = "export"
+	AS       = "as"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"macro":  MACRO,
-	"while":  WHILE,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"macro":    MACRO,
+	"while":    WHILE,
+	"break":    BREAK,
+	"for":      FOR,
+	"in":       IN,
+	"continue": CONTINUE,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"as":       AS,
 }
 
 func LookupIdent(ident string) TokenType {
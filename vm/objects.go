@@ -0,0 +1,127 @@
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/compiler"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/token"
+)
+
+// Closure is a function of the virtual machine. It is a FUNCTION for
+// Monkey code, like the functions of the evaluator.
+type Closure struct {
+	Fn      *compiler.CompiledFunction
+	Free    []*cell
+	Globals *object.Environment
+
+	vm *VM // the machine that runs it when a builtin calls it
+}
+
+func (cl *Closure) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (cl *Closure) Inspect() string {
+	var out bytes.Buffer
+
+	lit := cl.Fn.Literal
+	params := ast.FormatParameters(lit.Parameters, lit.Defaults, lit.Rest)
+
+	out.WriteString("fn")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(lit.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+// Call implements evaluator.Callable.
+func (cl *Closure) Call(args []object.Object, pos token.Position) object.Object {
+	return cl.vm.callNested(cl, args, pos)
+}
+
+// cell holds a variable shared by a frame and the closures created in it.
+type cell struct {
+	value object.Object
+}
+
+func (c *cell) Type() object.ObjectType { return "CELL" }
+func (c *cell) Inspect() string         { return "cell" }
+
+// undefinedValue marks a variable that is declared but not bound yet.
+type undefinedValue struct{ _ byte }
+
+func (u *undefinedValue) Type() object.ObjectType { return "UNDEFINED" }
+func (u *undefinedValue) Inspect() string         { return "undefined" }
+
+var undefined object.Object = &undefinedValue{}
+
+// iterator walks what a for-in loop iterates over, in the same way as the
+// evaluator.
+type iterator struct {
+	obj   object.Object
+	pairs []object.HashPair
+	index uint64
+	pos   int // byte offset in a string
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+func newIterator(obj object.Object) (*iterator, bool) {
+	switch obj := obj.(type) {
+	case *object.Array, *object.String, *object.Range:
+		return &iterator{obj: obj}, true
+	case *object.Hash:
+		return &iterator{obj: obj, pairs: obj.OrderedPairs()}, true
+	default:
+		return nil, false
+	}
+}
+
+// next returns the next key and value. Keys are indices for arrays,
+// strings and ranges.
+func (it *iterator) next() (key, value object.Object, ok bool) {
+	switch obj := it.obj.(type) {
+	case *object.Array:
+		if it.index >= uint64(len(obj.Elements)) {
+			return nil, nil, false
+		}
+		key, value = &object.Integer{Value: int64(it.index)}, obj.Elements[it.index]
+	case *object.Hash:
+		if it.index >= uint64(len(it.pairs)) {
+			return nil, nil, false
+		}
+		pair := it.pairs[it.index]
+		key, value = pair.Key, pair.Value
+	case *object.String:
+		if it.pos >= len(obj.Value) {
+			return nil, nil, false
+		}
+		r, size := utf8.DecodeRuneInString(obj.Value[it.pos:])
+		it.pos += size
+		key, value = &object.Integer{Value: int64(it.index)}, &object.String{Value: string(r)}
+	case *object.Range:
+		if it.index >= obj.Len() {
+			return nil, nil, false
+		}
+		key = object.NewInteger(new(big.Int).SetUint64(it.index))
+		value = &object.Integer{Value: obj.At(it.index)}
+	}
+
+	it.index++
+	return key, value, true
+}
+
+// element returns what a loop with one variable binds: the key of a hash
+// entry or the value of others.
+func (it *iterator) element(key, value object.Object) object.Object {
+	if _, ok := it.obj.(*object.Hash); ok {
+		return key
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package vm
+
+import (
+	"github.com/tatsuya4559/monkey/code"
+	"github.com/tatsuya4559/monkey/evaluator"
+	"github.com/tatsuya4559/monkey/object"
+)
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpGreaterThan:  ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+}
+
+var prefixOperators = map[code.Opcode]string{
+	code.OpMinus:  "-",
+	code.OpBang:   "!",
+	code.OpBitNot: "~",
+}
+
+// binaryOperation applies the infix operator of op. Small integers are
+// handled here as they are the most common operands.
+func binaryOperation(op code.Opcode, left, right object.Object) object.Object {
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if lok && rok {
+		switch op {
+		case code.OpAdd:
+			if sum := l.Value + r.Value; (sum > l.Value) == (r.Value > 0) {
+				return &object.Integer{Value: sum}
+			}
+		case code.OpSub:
+			if diff := l.Value - r.Value; (diff < l.Value) == (r.Value > 0) {
+				return &object.Integer{Value: diff}
+			}
+		case code.OpEqual:
+			return boolean(l.Value == r.Value)
+		case code.OpNotEqual:
+			return boolean(l.Value != r.Value)
+		case code.OpLessThan:
+			return boolean(l.Value < r.Value)
+		case code.OpGreaterThan:
+			return boolean(l.Value > r.Value)
+		case code.OpLessEqual:
+			return boolean(l.Value <= r.Value)
+		case code.OpGreaterEqual:
+			return boolean(l.Value >= r.Value)
+		}
+	}
+	return evaluator.Infix(infixOperators[op], left, right)
+}
+
+func unaryOperation(op code.Opcode, right object.Object) object.Object {
+	return evaluator.Prefix(prefixOperators[op], right)
+}
+
+func indexOperation(left, index object.Object) object.Object {
+	return evaluator.Index(left, index)
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case evaluator.TRUE:
+		return true
+	case evaluator.FALSE, evaluator.NULL:
+		return false
+	}
+	return evaluator.IsTruthy(obj)
+}
+
+func boolean(b bool) *object.Boolean {
+	if b {
+		return evaluator.TRUE
+	}
+	return evaluator.FALSE
+}
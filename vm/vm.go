@@ -0,0 +1,840 @@
+// Package vm runs the bytecode of the compiler. It has the same semantics
+// as the evaluator, which it calls for the operations they share.
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tatsuya4559/monkey/ast"
+	"github.com/tatsuya4559/monkey/code"
+	"github.com/tatsuya4559/monkey/compiler"
+	"github.com/tatsuya4559/monkey/evaluator"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/token"
+)
+
+const (
+	StackSize = 2048 // initial size; the stack grows as needed
+	MaxFrames = 1 << 18
+)
+
+type VM struct {
+	stack    []object.Object
+	sp       int // the top of the stack is stack[sp-1]
+	frames   []frame
+	handlers []handler
+}
+
+// frame is a call of a closure. Its arguments and locals are the slots
+// of the stack from bp on.
+type frame struct {
+	cl    *Closure
+	ip    int
+	bp    int
+	nargs int
+
+	// callIP is the offset of the call in the caller. A boundary frame is
+	// called from Go instead, at callPos, and run returns when it returns.
+	callIP   int
+	callPos  token.Position
+	boundary bool
+
+	// callers are the functions that made tail calls in the frame, which
+	// replace its function, and tailPos is the position of the last one
+	callers *evaluator.TailCallers
+	tailPos token.Position
+}
+
+// handler is the catch or finally clause of an active try expression.
+type handler struct {
+	frame int
+	ip    int
+	sp    int
+}
+
+func New() *VM {
+	return &VM{stack: make([]object.Object, StackSize)}
+}
+
+// Run compiles node, which must be a program, and runs it in env. It can
+// be used as evaluator.Engine.
+func Run(node ast.Node, env *object.Environment) object.Object {
+	program, ok := node.(*ast.Program)
+	if !ok {
+		return newError(object.RUNTIME_ERROR, "cannot compile %T", node)
+	}
+
+	if err := evaluator.Resolve(program, env); err != nil {
+		return err
+	}
+
+	main, err := compiler.Compile(program)
+	if err != nil {
+		return newError(object.RUNTIME_ERROR, "%s", err)
+	}
+	return New().Run(main, env)
+}
+
+// Run runs the main function of a program in env.
+func (vm *VM) Run(main *compiler.CompiledFunction, env *object.Environment) object.Object {
+	cl := &Closure{Fn: main, Globals: env, vm: vm}
+	return vm.callNested(cl, nil, token.Position{})
+}
+
+func newError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// callNested calls cl from Go and runs it to completion, on top of the
+// frames that are running, if any.
+func (vm *VM) callNested(cl *Closure, args []object.Object, pos token.Position) object.Object {
+	base := vm.sp
+	vm.ensure(base + 1 + len(args))
+	vm.stack[base] = cl
+	copy(vm.stack[base+1:], args)
+	vm.sp = base + 1 + len(args)
+
+	if err := vm.pushFrame(cl, len(args), 0, pos, true); err != nil {
+		vm.sp = base
+		return err
+	}
+	return vm.run()
+}
+
+// pushFrame calls cl with the nargs arguments on the top of the stack.
+func (vm *VM) pushFrame(
+	cl *Closure,
+	nargs int,
+	callIP int,
+	pos token.Position,
+	boundary bool,
+) *object.Error {
+	fn := cl.Fn
+	if nargs != fn.NumParams || fn.Rest {
+		if err := evaluator.ArityError(fn.MinParams, fn.NumParams, fn.Rest, nargs); err != nil {
+			return err
+		}
+	}
+	if len(vm.frames) >= MaxFrames {
+		return newError(object.RUNTIME_ERROR, "maximum recursion depth exceeded")
+	}
+
+	bp := vm.sp - nargs
+	vm.initLocals(fn, bp, nargs)
+
+	vm.frames = append(vm.frames, frame{
+		cl:       cl,
+		bp:       bp,
+		nargs:    nargs,
+		callIP:   callIP,
+		callPos:  pos,
+		boundary: boundary,
+	})
+	if evaluator.Profile != nil && fn.Literal != nil {
+		evaluator.Profile.Enter(fn.Name, fn.Literal.Pos())
+	}
+	return nil
+}
+
+// initLocals fills the slots of the locals of fn above the nargs arguments
+// at bp.
+func (vm *VM) initLocals(fn *compiler.CompiledFunction, bp, nargs int) {
+	vm.ensure(bp + fn.NumLocals + fn.MaxStack)
+
+	if fn.Rest {
+		rest := []object.Object{}
+		if nargs > fn.NumParams {
+			rest = append(rest, vm.stack[bp+fn.NumParams:vm.sp]...)
+			vm.sp = bp + fn.NumParams
+		}
+		for vm.sp < bp+fn.NumParams {
+			vm.push(undefined)
+		}
+		vm.push(&object.Array{Elements: rest})
+	}
+	for vm.sp < bp+fn.NumLocals {
+		vm.push(undefined)
+	}
+}
+
+// ensure grows the stack to at least size slots.
+func (vm *VM) ensure(size int) {
+	if size <= len(vm.stack) {
+		return
+	}
+	n := 2 * len(vm.stack)
+	if n < size {
+		n = size
+	}
+	stack := make([]object.Object, n)
+	copy(stack, vm.stack[:vm.sp])
+	vm.stack = stack
+}
+
+func (vm *VM) push(obj object.Object) {
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+// popN pops n values and returns a copy of them.
+func (vm *VM) popN(n int) []object.Object {
+	values := make([]object.Object, n)
+	copy(values, vm.stack[vm.sp-n:vm.sp])
+	vm.sp -= n
+	return values
+}
+
+// run executes instructions until the innermost boundary frame returns.
+// It returns the result or an error that was not caught.
+func (vm *VM) run() object.Object {
+	for {
+		f := &vm.frames[len(vm.frames)-1]
+		fn := f.cl.Fn
+		ins := fn.Instructions
+		ip := f.ip
+		op := code.Opcode(ins[ip])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			f.ip = ip + 3
+			vm.push(fn.Constants[code.ReadUint16(ins[ip+1:])])
+
+		case code.OpNone:
+			f.ip = ip + 1
+			vm.push(nil)
+
+		case code.OpNull:
+			f.ip = ip + 1
+			vm.push(evaluator.NULL)
+
+		case code.OpTrue:
+			f.ip = ip + 1
+			vm.push(evaluator.TRUE)
+
+		case code.OpFalse:
+			f.ip = ip + 1
+			vm.push(evaluator.FALSE)
+
+		case code.OpPop:
+			f.ip = ip + 1
+			vm.sp--
+
+		case code.OpPopN:
+			f.ip = ip + 3
+			vm.sp -= int(code.ReadUint16(ins[ip+1:]))
+
+		case code.OpNip:
+			f.ip = ip + 1
+			vm.stack[vm.sp-2] = vm.stack[vm.sp-1]
+			vm.sp--
+
+		case code.OpDup:
+			f.ip = ip + 1
+			vm.push(vm.stack[vm.sp-1])
+
+		case code.OpDup2:
+			f.ip = ip + 1
+			vm.push(vm.stack[vm.sp-2])
+			vm.push(vm.stack[vm.sp-2])
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft,
+			code.OpShiftRight, code.OpEqual, code.OpNotEqual, code.OpLessThan,
+			code.OpGreaterThan, code.OpLessEqual, code.OpGreaterEqual:
+			f.ip = ip + 1
+			right := vm.pop()
+			left := vm.pop()
+			result := binaryOperation(op, left, right)
+			if e, ok := result.(*object.Error); ok {
+				err = e
+				break
+			}
+			vm.push(result)
+
+		case code.OpMinus, code.OpBang, code.OpBitNot:
+			f.ip = ip + 1
+			result := unaryOperation(op, vm.pop())
+			if e, ok := result.(*object.Error); ok {
+				err = e
+				break
+			}
+			vm.push(result)
+
+		case code.OpJump:
+			f.ip = int(code.ReadUint16(ins[ip+1:]))
+
+		case code.OpJumpNotTruthy:
+			f.ip = ip + 3
+			if !isTruthy(vm.pop()) {
+				f.ip = int(code.ReadUint16(ins[ip+1:]))
+			}
+
+		case code.OpJumpFalsyOrPop:
+			f.ip = ip + 3
+			if !isTruthy(vm.stack[vm.sp-1]) {
+				f.ip = int(code.ReadUint16(ins[ip+1:]))
+			} else {
+				vm.sp--
+			}
+
+		case code.OpJumpTruthyOrPop:
+			f.ip = ip + 3
+			if isTruthy(vm.stack[vm.sp-1]) {
+				f.ip = int(code.ReadUint16(ins[ip+1:]))
+			} else {
+				vm.sp--
+			}
+
+		case code.OpJumpIfArg:
+			f.ip = ip + 5
+			if int(code.ReadUint16(ins[ip+1:])) < f.nargs {
+				f.ip = int(code.ReadUint16(ins[ip+3:]))
+			}
+
+		case code.OpGetGlobal:
+			f.ip = ip + 3
+			name := fn.Constants[code.ReadUint16(ins[ip+1:])].(*object.String).Value
+			var val object.Object
+			val, err = vm.get(f, name)
+			if err == nil {
+				vm.push(val)
+			}
+
+		case code.OpSetGlobal:
+			f.ip = ip + 3
+			name := fn.Constants[code.ReadUint16(ins[ip+1:])].(*object.String).Value
+			f.cl.Globals.Set(name, vm.pop())
+
+		case code.OpAssignGlobal:
+			f.ip = ip + 3
+			name := fn.Constants[code.ReadUint16(ins[ip+1:])].(*object.String).Value
+			if !f.cl.Globals.Assign(name, vm.pop()) {
+				err = undeclared(name)
+			}
+
+		case code.OpGetLocal:
+			f.ip = ip + 3
+			slot := int(code.ReadUint16(ins[ip+1:]))
+			val := vm.stack[f.bp+slot]
+			if val == undefined {
+				err = notFound(fn.Locals[slot])
+				break
+			}
+			vm.push(val)
+
+		case code.OpSetLocal:
+			f.ip = ip + 3
+			vm.stack[f.bp+int(code.ReadUint16(ins[ip+1:]))] = vm.pop()
+
+		case code.OpAssignLocal:
+			f.ip = ip + 3
+			slot := int(code.ReadUint16(ins[ip+1:]))
+			val := vm.pop()
+			if vm.stack[f.bp+slot] == undefined {
+				err = undeclared(fn.Locals[slot])
+				break
+			}
+			vm.stack[f.bp+slot] = val
+
+		case code.OpGetCell:
+			f.ip = ip + 3
+			slot := int(code.ReadUint16(ins[ip+1:]))
+			val := vm.stack[f.bp+slot].(*cell).value
+			if val == undefined {
+				err = notFound(fn.Locals[slot])
+				break
+			}
+			vm.push(val)
+
+		case code.OpSetCell:
+			f.ip = ip + 3
+			vm.stack[f.bp+int(code.ReadUint16(ins[ip+1:]))].(*cell).value = vm.pop()
+
+		case code.OpAssignCell:
+			f.ip = ip + 3
+			slot := int(code.ReadUint16(ins[ip+1:]))
+			val := vm.pop()
+			c := vm.stack[f.bp+slot].(*cell)
+			if c.value == undefined {
+				err = undeclared(fn.Locals[slot])
+				break
+			}
+			c.value = val
+
+		case code.OpGetFree:
+			f.ip = ip + 3
+			index := int(code.ReadUint16(ins[ip+1:]))
+			val := f.cl.Free[index].value
+			if val == undefined {
+				err = notFound(fn.Free[index])
+				break
+			}
+			vm.push(val)
+
+		case code.OpAssignFree:
+			f.ip = ip + 3
+			index := int(code.ReadUint16(ins[ip+1:]))
+			val := vm.pop()
+			c := f.cl.Free[index]
+			if c.value == undefined {
+				err = undeclared(fn.Free[index])
+				break
+			}
+			c.value = val
+
+		case code.OpCheckAssign:
+			f.ip = ip + 4
+			sym := compiler.Symbol{
+				Scope: compiler.SymbolScope(ins[ip+1]),
+				Index: int(code.ReadUint16(ins[ip+2:])),
+			}
+			switch sym.Scope {
+			case compiler.GlobalScope:
+				sym.Name = fn.Constants[sym.Index].(*object.String).Value
+			case compiler.FreeScope:
+				sym.Name = fn.Free[sym.Index]
+			default:
+				sym.Name = fn.Locals[sym.Index]
+			}
+			if _, ok := vm.lookup(f, sym); !ok {
+				err = undeclared(sym.Name)
+			}
+
+		case code.OpUnset:
+			f.ip = ip + 3
+			vm.stack[f.bp+int(code.ReadUint16(ins[ip+1:]))] = undefined
+
+		case code.OpMakeCell:
+			f.ip = ip + 3
+			slot := f.bp + int(code.ReadUint16(ins[ip+1:]))
+			vm.stack[slot] = &cell{value: vm.stack[slot]}
+
+		case code.OpLoadCell:
+			f.ip = ip + 3
+			vm.push(vm.stack[f.bp+int(code.ReadUint16(ins[ip+1:]))])
+
+		case code.OpLoadFree:
+			f.ip = ip + 3
+			vm.push(f.cl.Free[code.ReadUint16(ins[ip+1:])])
+
+		case code.OpArray:
+			f.ip = ip + 3
+			elements := vm.popN(int(code.ReadUint16(ins[ip+1:])))
+			vm.push(&object.Array{Elements: elements})
+
+		case code.OpHash:
+			f.ip = ip + 3
+			n := int(code.ReadUint16(ins[ip+1:]))
+			hash := object.NewHash()
+			for i := vm.sp - 2*n; i < vm.sp; i += 2 {
+				key, value := vm.stack[i], vm.stack[i+1]
+				hashKey := key.(object.Hashable).HashKey()
+				hash.Set(hashKey, object.HashPair{Key: key, Value: value})
+			}
+			vm.sp -= 2 * n
+			vm.push(hash)
+
+		case code.OpHashKey:
+			f.ip = ip + 1
+			key := vm.stack[vm.sp-1]
+			if _, ok := key.(object.Hashable); !ok {
+				err = newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
+			}
+
+		case code.OpAppend:
+			f.ip = ip + 1
+			val := vm.pop()
+			array := vm.stack[vm.sp-1].(*object.Array)
+			array.Elements = append(array.Elements, val)
+
+		case code.OpSpread:
+			f.ip = ip + 1
+			val := vm.pop()
+			spread, ok := val.(*object.Array)
+			if !ok {
+				err = newError(object.TYPE_ERROR, "cannot spread %s", val.Type())
+				break
+			}
+			array := vm.stack[vm.sp-1].(*object.Array)
+			array.Elements = append(array.Elements, spread.Elements...)
+
+		case code.OpInterpolate:
+			f.ip = ip + 3
+			var out strings.Builder
+			for _, part := range vm.popN(int(code.ReadUint16(ins[ip+1:]))) {
+				if str, ok := part.(*object.String); ok {
+					out.WriteString(str.Value)
+				} else {
+					out.WriteString(part.Inspect())
+				}
+			}
+			vm.push(&object.String{Value: out.String()})
+
+		case code.OpIndex:
+			f.ip = ip + 1
+			index := vm.pop()
+			left := vm.pop()
+			result := indexOperation(left, index)
+			if e, ok := result.(*object.Error); ok {
+				err = e
+				break
+			}
+			vm.push(result)
+
+		case code.OpSlice:
+			f.ip = ip + 2
+			flags := int(ins[ip+1])
+			var bounds [3]object.Object
+			for i := 2; i >= 0; i-- {
+				if flags&(1<<i) != 0 {
+					bounds[i] = vm.pop()
+				}
+			}
+			result := evaluator.Slice(vm.pop(), bounds[0], bounds[1], bounds[2])
+			if e, ok := result.(*object.Error); ok {
+				err = e
+				break
+			}
+			vm.push(result)
+
+		case code.OpCheckIndex:
+			f.ip = ip + 1
+			err = evaluator.CheckIndexAssignment(vm.stack[vm.sp-2], vm.stack[vm.sp-1])
+
+		case code.OpIndexedValue:
+			f.ip = ip + 1
+			index := vm.pop()
+			left := vm.pop()
+			result := evaluator.IndexedValue(left, index)
+			if e, ok := result.(*object.Error); ok {
+				err = e
+				break
+			}
+			vm.push(result)
+
+		case code.OpSetIndex:
+			f.ip = ip + 1
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			evaluator.SetIndex(left, index, val)
+			vm.push(val)
+
+		case code.OpMember:
+			f.ip = ip + 3
+			name := fn.Constants[code.ReadUint16(ins[ip+1:])].(*object.String).Value
+			result := evaluator.Member(vm.pop(), name)
+			if e, ok := result.(*object.Error); ok {
+				err = e
+				break
+			}
+			vm.push(result)
+
+		case code.OpClosure:
+			f.ip = ip + 5
+			compiled := fn.Constants[code.ReadUint16(ins[ip+1:])].(*compiler.CompiledFunction)
+			n := int(code.ReadUint16(ins[ip+3:]))
+			free := make([]*cell, n)
+			for i := 0; i < n; i++ {
+				free[i] = vm.stack[vm.sp-n+i].(*cell)
+			}
+			vm.sp -= n
+			vm.push(&Closure{Fn: compiled, Free: free, Globals: f.cl.Globals, vm: vm})
+
+		case code.OpCall:
+			f.ip = ip + 3
+			err = vm.call(int(code.ReadUint16(ins[ip+1:])), ip)
+
+		case code.OpCallSpread:
+			f.ip = ip + 1
+			err = vm.call(vm.spread(), ip)
+
+		case code.OpTailCall:
+			f.ip = ip + 3
+			err = vm.tailCall(int(code.ReadUint16(ins[ip+1:])), ip)
+
+		case code.OpTailCallSpread:
+			f.ip = ip + 1
+			err = vm.tailCall(vm.spread(), ip)
+
+		case code.OpReturnValue:
+			result := vm.pop()
+			vm.leave()
+			vm.sp = f.bp - 1
+			if f.boundary {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpIter:
+			f.ip = ip + 1
+			iterable := vm.pop()
+			it, ok := newIterator(iterable)
+			if !ok {
+				err = newError(object.TYPE_ERROR, "cannot iterate over %s", iterable.Type())
+				break
+			}
+			vm.push(it)
+
+		case code.OpIterNext:
+			f.ip = ip + 4
+			it := vm.stack[vm.sp-2].(*iterator)
+			key, value, ok := it.next()
+			switch {
+			case !ok:
+				f.ip = int(code.ReadUint16(ins[ip+1:]))
+			case ins[ip+3] == code.IterPair:
+				vm.push(key)
+				vm.push(value)
+			default:
+				vm.push(it.element(key, value))
+			}
+
+		case code.OpSetupTry:
+			f.ip = ip + 3
+			vm.handlers = append(vm.handlers, handler{
+				frame: len(vm.frames) - 1,
+				ip:    int(code.ReadUint16(ins[ip+1:])),
+				sp:    vm.sp,
+			})
+
+		case code.OpPopTry:
+			f.ip = ip + 1
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpRaise:
+			f.ip = ip + 1
+			err = vm.pop().(*object.Error)
+
+		case code.OpThrow:
+			f.ip = ip + 1
+			err = evaluator.Throw(vm.pop())
+
+		case code.OpErrorToHash:
+			f.ip = ip + 1
+			vm.push(evaluator.ErrorToHash(vm.pop().(*object.Error)))
+
+		case code.OpImport:
+			f.ip = ip + 3
+			quote := fn.Constants[code.ReadUint16(ins[ip+1:])].(*object.Quote)
+			result := evaluator.Import(quote.Node.(*ast.ImportStatement), f.cl.Globals)
+			if e, ok := result.(*object.Error); ok {
+				err = e
+			}
+
+		case code.OpQuote:
+			f.ip = ip + 5
+			quote := fn.Constants[code.ReadUint16(ins[ip+1:])].(*object.Quote)
+			values := vm.popN(int(code.ReadUint16(ins[ip+3:])))
+			call := quote.Node.(*ast.CallExpression)
+			if len(call.Arguments) != 1 {
+				err = newError(object.ARGUMENT_ERROR,
+					"wrong number of arguments. want=1, got=%d", len(call.Arguments))
+				break
+			}
+			vm.push(evaluator.Quote(call.Arguments[0], values))
+
+		default:
+			def, _ := code.Lookup(byte(op))
+			err = newError(object.RUNTIME_ERROR, "unknown instruction: %v", def)
+		}
+
+		if err != nil && !vm.unwind(err, ip) {
+			return err
+		}
+	}
+}
+
+// call calls the function below the nargs arguments on the top of the
+// stack. ip is the offset of the call instruction.
+func (vm *VM) call(nargs int, ip int) *object.Error {
+	callee := vm.stack[vm.sp-1-nargs]
+	if cl, ok := callee.(*Closure); ok {
+		return vm.pushFrame(cl, nargs, ip, token.Position{}, false)
+	}
+
+	args := vm.popN(nargs)
+	vm.sp--
+
+	var result object.Object
+	if builtin, ok := callee.(*object.Builtin); ok {
+		if evaluator.Profile != nil {
+			evaluator.Profile.Enter(builtin.Name, token.Position{})
+		}
+		result = builtin.Fn(args...)
+		if evaluator.Profile != nil {
+			evaluator.Profile.Exit()
+		}
+	} else {
+		f := &vm.frames[len(vm.frames)-1]
+		result = evaluator.Apply(callee, args, f.cl.Fn.PosAt(ip))
+	}
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+	vm.push(result)
+	return nil
+}
+
+// spread pushes the elements of the array on the top as arguments and
+// returns their number.
+func (vm *VM) spread() int {
+	args := vm.pop().(*object.Array).Elements
+	vm.ensure(vm.sp + len(args))
+	for _, arg := range args {
+		vm.push(arg)
+	}
+	return len(args)
+}
+
+// tailCall calls the function below the nargs arguments on the top in
+// place of the function of the innermost frame, as the evaluator applies
+// calls in tail position once the function making them returns. Other
+// callees are called as usual.
+func (vm *VM) tailCall(nargs int, ip int) *object.Error {
+	cl, ok := vm.stack[vm.sp-1-nargs].(*Closure)
+	if !ok {
+		return vm.call(nargs, ip)
+	}
+
+	i := len(vm.frames) - 1
+	f := &vm.frames[i]
+	caller := object.Frame{Function: f.cl.Fn.Name, Pos: vm.callPos(i)}
+	if f.callers == nil {
+		f.callers = &evaluator.TailCallers{}
+	}
+	f.callers.Add(caller)
+	pos := f.cl.Fn.PosAt(ip)
+	f.tailPos = pos
+
+	fn := cl.Fn
+	if evaluator.Profile != nil {
+		evaluator.Profile.Exit()
+		evaluator.Profile.Enter(fn.Name, fn.Literal.Pos())
+	}
+
+	// the callee and its arguments take the place of those of the caller
+	copy(vm.stack[f.bp-1:], vm.stack[vm.sp-1-nargs:vm.sp])
+	vm.sp = f.bp + nargs
+	f.cl, f.ip, f.nargs = cl, 0, nargs
+
+	if nargs != fn.NumParams || fn.Rest {
+		if err := evaluator.ArityError(fn.MinParams, fn.NumParams, fn.Rest, nargs); err != nil {
+			err.Pos = pos
+			return err
+		}
+	}
+	vm.initLocals(fn, f.bp, nargs)
+	return nil
+}
+
+// unwind passes err to the innermost handler, leaving the frames in
+// between and recording them in the stack trace. ip is the offset of the
+// instruction that failed. It reports false if err leaves the innermost
+// boundary frame, which run returns.
+func (vm *VM) unwind(err *object.Error, ip int) bool {
+	if !err.Pos.IsValid() {
+		f := &vm.frames[len(vm.frames)-1]
+		err.Pos = f.cl.Fn.PosAt(ip)
+	}
+
+	for {
+		i := len(vm.frames) - 1
+		f := &vm.frames[i]
+
+		if n := len(vm.handlers); n > 0 && vm.handlers[n-1].frame == i {
+			h := vm.handlers[n-1]
+			vm.handlers = vm.handlers[:n-1]
+			vm.sp = h.sp
+			vm.push(err)
+			f.ip = h.ip
+			return true
+		}
+
+		// like the evaluator, a call does not count until its parameters
+		// are bound
+		fn := f.cl.Fn
+		if fn.Literal != nil && f.ip > fn.BodyStart {
+			err.Trace = append(err.Trace, object.Frame{Function: fn.Name, Pos: vm.callPos(i)})
+		}
+		f.callers.AppendTo(err)
+
+		vm.leave()
+		vm.sp = f.bp - 1
+		if f.boundary {
+			return false
+		}
+	}
+}
+
+// leave pops the innermost frame.
+func (vm *VM) leave() {
+	fn := vm.frames[len(vm.frames)-1].cl.Fn
+	if evaluator.Profile != nil && fn.Literal != nil {
+		evaluator.Profile.Exit()
+	}
+	vm.frames = vm.frames[:len(vm.frames)-1]
+}
+
+// callPos returns the position of the call of the function of frames[i],
+// which is a tail call if it made any.
+func (vm *VM) callPos(i int) token.Position {
+	if f := &vm.frames[i]; f.callers != nil {
+		return f.tailPos
+	}
+	return vm.callSite(i)
+}
+
+// callSite returns the position of the call of frames[i].
+func (vm *VM) callSite(i int) token.Position {
+	f := &vm.frames[i]
+	if f.boundary {
+		return f.callPos
+	}
+	return vm.frames[i-1].cl.Fn.PosAt(f.callIP)
+}
+
+// lookup returns the value of sym in f. It reports false if the variable
+// is not bound.
+func (vm *VM) lookup(f *frame, sym compiler.Symbol) (object.Object, bool) {
+	var val object.Object
+	switch sym.Scope {
+	case compiler.GlobalScope:
+		return f.cl.Globals.Get(sym.Name)
+	case compiler.LocalScope:
+		val = vm.stack[f.bp+sym.Index]
+	case compiler.CellScope:
+		val = vm.stack[f.bp+sym.Index].(*cell).value
+	case compiler.FreeScope:
+		val = f.cl.Free[sym.Index].value
+	}
+	return val, val != undefined
+}
+
+// get returns the value of the global variable or the builtin called
+// name, as the evaluator evaluates an identifier without a binding.
+func (vm *VM) get(f *frame, name string) (object.Object, *object.Error) {
+	if val, ok := f.cl.Globals.Get(name); ok {
+		return val, nil
+	}
+	if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		return builtin, nil
+	}
+	return nil, notFound(name)
+}
+
+func notFound(name string) *object.Error {
+	return newError(object.NAME_ERROR, "identifier not found: %s", name)
+}
+
+func undeclared(name string) *object.Error {
+	return newError(object.NAME_ERROR, "assignment to undeclared variable: %s", name)
+}
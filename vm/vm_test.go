@@ -0,0 +1,119 @@
+package vm
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/tatsuya4559/monkey/evaluator"
+	"github.com/tatsuya4559/monkey/lexer"
+	"github.com/tatsuya4559/monkey/object"
+	"github.com/tatsuya4559/monkey/parser"
+)
+
+func testRun(t *testing.T, input string) object.Object {
+	t.Helper()
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, errs := p.ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+	return Run(program, object.NewEnvironment())
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Inspect() of the result
+	}{
+		{"1 + 2 * 3", "7"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)`, "610"},
+		{`let f = fn() { let c = 0; fn() { c += 1; c } }; let g = f(); g(); g()`, "2"},
+		{`let fs = []; for i in range(3) { fs = push(fs, fn() { i }) } map(fs, fn(f) { f() })`,
+			"[0, 1, 2]"},
+		{`let x = 1; let f = fn() { let y = x; let x = 2; [y, x] }; f()`, "[1, 2]"},
+		{`let f = fn(a, b = a + 1, ...rest) { [a, b, rest] }; f(1, ...[2, 3, 4])`,
+			"[1, 2, [3, 4]]"},
+		{`let f = fn() { for x in [1, 2, 3] { try { if (x == 2) { return x; } } finally { x } } }; f()`,
+			"2"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100000)`, "100000"},
+	}
+
+	for _, tt := range tests {
+		result := testRun(t, tt.input)
+		if result == nil || result.Inspect() != tt.expected {
+			t.Errorf("%s: want=%s, got=%v", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestRecursionLimit(t *testing.T) {
+	result := testRun(t, `let f = fn() { 1 + f() }; f()`)
+
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", result, result)
+	}
+	if errObj.Message != "maximum recursion depth exceeded" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+	if len(errObj.Trace) != MaxFrames-1 {
+		t.Errorf("wrong number of frames. want=%d, got=%d", MaxFrames-1, len(errObj.Trace))
+	}
+}
+
+func TestCallback(t *testing.T) {
+	// builtins call closures back on the same machine, and errors leave
+	// them with the frames of both
+	input := `let f = fn(x) { 1 / x };
+let g = fn(xs) { map(xs, f) };
+g([1, 0])`
+
+	result := testRun(t, input)
+
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", result, result)
+	}
+	expected := []object.Frame{{Function: "f"}, {Function: "g"}}
+	if len(errObj.Trace) != len(expected) {
+		t.Fatalf("wrong number of frames. want=%d, got=%d", len(expected), len(errObj.Trace))
+	}
+	for i, frame := range expected {
+		if errObj.Trace[i].Function != frame.Function {
+			t.Errorf("wrong function of frame %d. want=%s, got=%s",
+				i, frame.Function, errObj.Trace[i].Function)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	evaluator.Engine = Run
+	defer func() { evaluator.Engine = evaluator.Eval }()
+
+	dir := t.TempDir()
+	src := `let count = 0; export let inc = fn() { count += 1; count };`
+	if err := ioutil.WriteFile(filepath.Join(dir, "counter.mnk"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := lexer.NewFile(filepath.Join(dir, "main.mnk"), `import "counter.mnk" as c; [c.inc(), c.inc(), c.inc]`)
+	program, errs := parser.New(l).ParseProgram()
+	if len(errs) != 0 {
+		t.Fatalf("parse error: %v", errs)
+	}
+
+	result, ok := Run(program, object.NewEnvironment()).(*object.Array)
+	if !ok || len(result.Elements) != 3 {
+		t.Fatalf("result is not Array of 3 elements. got=%+v", result)
+	}
+	if result.Elements[1].Inspect() != "2" {
+		t.Errorf("wrong result. want=2, got=%s", result.Elements[1].Inspect())
+	}
+	// the module is run by the machine too
+	if _, ok := result.Elements[2].(*Closure); !ok {
+		t.Errorf("member is not Closure. got=%T", result.Elements[2])
+	}
+}